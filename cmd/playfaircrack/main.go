@@ -1,12 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"playfaircrack/internal/cipher"
 	"playfaircrack/internal/cmdutil"
 	"playfaircrack/internal/crack"
-	"playfaircrack/internal/score"
 	"time"
 
 	"github.com/urfave/cli/v2"
@@ -14,8 +14,25 @@ import (
 
 // Global cli arguments
 var key string
+var key2 string
+var cipherName string
+var period int
+var rulesName string
+var conventionName string
+var envelope bool
 var filepath string
 var logVerbose bool
+var timeout time.Duration
+var crib string
+var fix string
+var plaintext string
+var merge string
+var omit string
+var reduction cmdutil.Reduction
+var grid string
+var alphabet string
+var layout cipher.Layout
+var crackOpts = crack.DefaultCrackOptions()
 
 func main() {
 	app := &cli.App{
@@ -29,6 +46,42 @@ func main() {
 				Email: "theo.siemensrhodes@gmail.com",
 			},
 		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "merge",
+				Destination: &merge,
+				Usage:       "Fold the second letter of `PAIR` into the first, like IJ (the default) or CK",
+			},
+			&cli.StringFlag{
+				Name:        "omit",
+				Destination: &omit,
+				Usage:       "Drop `LETTER` from the plaintext instead of merging, like Q",
+			},
+			&cli.StringFlag{
+				Name:        "grid",
+				Destination: &grid,
+				Value:       "5x5",
+				Usage:       "Use a key grid of `SHAPE`, like 5x5 or 6x6 for letters and digits",
+			},
+			&cli.StringFlag{
+				Name:        "alphabet",
+				Destination: &alphabet,
+				Usage:       "Fill the key grid with `SYMBOLS`, one per cell, needed for shapes other than 5x5 and 6x6",
+			},
+		},
+		Before: func(cCtx *cli.Context) error {
+			var err error
+			err, reduction = cmdutil.ValidateAndTransformReduction(merge, omit)
+			if err != nil {
+				return err
+			}
+
+			err, layout = cmdutil.ValidateAndTransformLayout(grid, alphabet, reduction)
+			if err == nil && !layout.IsStandard() && (merge != "" || omit != "") {
+				err = fmt.Errorf("Letters can only be merged or omitted on 5x5 grids of letters")
+			}
+			return err
+		},
 		Commands: []*cli.Command{
 			{
 				Name:    "crack",
@@ -47,6 +100,128 @@ func main() {
 						Destination: &logVerbose,
 						Usage:       "Log the cracking process verbosely",
 					},
+					&cli.StringFlag{
+						Name:        "cipher",
+						Destination: &cipherName,
+						Value:       "playfair",
+						Usage:       "Use the `CIPHER` playfair, twosquare, twosquare-vertical, foursquare or doubleplayfair",
+					},
+					&cli.StringFlag{
+						Name:        "rules",
+						Destination: &rulesName,
+						Value:       "standard",
+						Usage:       "Map Playfair digraphs by the `RULES` standard, left-up, opposite-corner or same-row-unchanged",
+					},
+					&cli.IntFlag{
+						Name:        "period",
+						Destination: &crackOpts.Period,
+						Usage:       "Crack Seriated Playfair with rows of `N` letters",
+					},
+					&cli.BoolFlag{
+						Name:        "search-period",
+						Destination: &crackOpts.SearchPeriods,
+						Usage:       "Crack Seriated Playfair of unknown period, trying every period the ciphertext allows",
+					},
+					&cli.DurationFlag{
+						Name:        "timeout",
+						Aliases:     []string{"t"},
+						Destination: &timeout,
+						Usage:       "Give up after `DURATION` and print the best unconfirmed key, 0 to never give up",
+					},
+					&cli.StringFlag{
+						Name:        "crib",
+						Destination: &crib,
+						Usage:       "Only search keys that encrypt `WORD` somewhere in the ciphertext",
+					},
+					&cli.StringFlag{
+						Name:        "fix",
+						Destination: &fix,
+						Usage:       "Pin letters to key cells, as `PINS` like \"P@0,L@1\" or a grid like \"PLAY?FIR??...\"",
+					},
+					&cli.IntFlag{
+						Name:        "exhaustive-limit",
+						Destination: &crackOpts.ExhaustiveLimit,
+						Value:       crackOpts.ExhaustiveLimit,
+						Usage:       "Try every key instead of annealing when `N` or fewer cells are free",
+					},
+					&cli.BoolFlag{
+						Name:        "dictionary",
+						Destination: &crackOpts.DictionaryAttack,
+						Value:       crackOpts.DictionaryAttack,
+						Usage:       "Try every dictionary word as the keyword before annealing, --dictionary=false to skip",
+					},
+					&cli.Int64Flag{
+						Name:        "seed",
+						Destination: &crackOpts.Seed,
+						Usage:       "Seed the search with `SEED` to reproduce a run, 0 picks one from the clock",
+					},
+					&cli.Float64Flag{
+						Name:        "temp",
+						Destination: &crackOpts.InitialTemp,
+						Value:       crackOpts.InitialTemp,
+						Usage:       "Start each annealing run at `TEMP`",
+					},
+					&cli.Float64Flag{
+						Name:        "floor-temp",
+						Destination: &crackOpts.FloorTemp,
+						Value:       crackOpts.FloorTemp,
+						Usage:       "Restart annealing once the temperature drops below `TEMP`",
+					},
+					&cli.Float64Flag{
+						Name:        "cooling-rate",
+						Destination: &crackOpts.CoolingRate,
+						Value:       crackOpts.CoolingRate,
+						Usage:       "Cool the temperature by `RATE` every epoch",
+					},
+					&cli.IntFlag{
+						Name:        "epoch-tries",
+						Destination: &crackOpts.TriesPerEpoch,
+						Value:       crackOpts.TriesPerEpoch,
+						Usage:       "Try `N` keys per epoch",
+					},
+					&cli.IntFlag{
+						Name:        "epochs",
+						Destination: &crackOpts.Epochs,
+						Value:       crackOpts.Epochs,
+						Usage:       "Stop once every worker has annealed for `N` epochs, 0 for no limit",
+					},
+					&cli.IntFlag{
+						Name:        "stagnation-tries",
+						Destination: &crackOpts.TriesBeforeStagnation,
+						Value:       crackOpts.TriesBeforeStagnation,
+						Usage:       "Submit the best key after `N` tries without improvement",
+					},
+					&cli.Float64Flag{
+						Name:        "score-gate",
+						Destination: &crackOpts.ScoreGate,
+						Value:       crackOpts.ScoreGate,
+						Usage:       "Only submit keys scoring above `SCORE`",
+					},
+					&cli.Float64Flag{
+						Name:        "genetic-multiplier",
+						Destination: &crackOpts.GeneticTempMultiplier,
+						Value:       crackOpts.GeneticTempMultiplier,
+						Usage:       "Share keys within a pool at `MULTIPLIER` times the temperature",
+					},
+					&cli.IntFlag{
+						Name:        "top",
+						Destination: &crackOpts.TopN,
+						Value:       crackOpts.TopN,
+						Usage:       "List the `N` best distinct keys found",
+					},
+					&cli.IntFlag{
+						Name:        "threads",
+						Aliases:     []string{"j"},
+						Destination: &crackOpts.Threads,
+						Value:       crackOpts.Threads,
+						Usage:       "Run `N` annealing workers in total, 0 for one per CPU",
+					},
+					&cli.IntFlag{
+						Name:        "pool-size",
+						Destination: &crackOpts.PoolSize,
+						Value:       crackOpts.PoolSize,
+						Usage:       "Run `N` workers per pool",
+					},
 				},
 				Action: func(cCtx *cli.Context) error {
 					err, text := cmdutil.GatherInput(filepath)
@@ -54,17 +229,70 @@ func main() {
 						return err
 					}
 
-					err, ciphertext := cmdutil.ValidateAndTransformCiphertext(text, 'J')
+					cipherType, err := cipher.ParseType(cipherName)
 					if err != nil {
 						return err
 					}
 
-					result := crack.PlayfairCrack(ciphertext, 'J', 'X', logVerbose)
+					err, rules := cmdutil.ValidateAndTransformRules(rulesName, cipherType)
+					if err != nil {
+						return err
+					}
+
+					doubles := cipherType.AllowsDoubles() || crackOpts.SearchPeriods
+					err, ciphertext := cmdutil.ValidateAndTransformLayoutCiphertext(text, layout, doubles, crackOpts.Period)
+					if err != nil {
+						return err
+					}
+
+					ctx := context.Background()
+					if timeout > 0 {
+						var cancel context.CancelFunc
+						ctx, cancel = context.WithTimeout(ctx, timeout)
+						defer cancel()
+					}
+
+					if crib != "" {
+						err, crackOpts.Crib = cmdutil.ValidateAndTransformCrib(crib, reduction.Excluded, reduction.Replacement)
+						if err != nil {
+							return err
+						}
+					}
+
+					if fix != "" {
+						err, crackOpts.Pins = cmdutil.ValidateAndTransformPins(fix, reduction.Excluded, reduction.Replacement)
+						if err != nil {
+							return err
+						}
+					}
+
+					crackOpts.ExcludedLetter = byte(reduction.Excluded)
+					crackOpts.ReplacementLetter = byte(reduction.Replacement)
+					crackOpts.Layout = layout
+					crackOpts.Cipher = cipherType
+					crackOpts.Rules = rules
+					crackOpts.LogVerbose = logVerbose
+
+					result, err := crack.PlayfairCrack(ctx, ciphertext, crackOpts)
+					if err != nil {
+						return err
+					}
+					if result.Key == "" {
+						return fmt.Errorf("No key was scored before the timeout")
+					}
 
 					// Log result
 					if logVerbose {
-						fmt.Printf("\nSolution found, in %v!\n", result.ElapsedTime)
+						if result.Confirmed {
+							fmt.Printf("\nSolution found, in %v!\n", result.ElapsedTime)
+						} else {
+							fmt.Printf("\nNo solution confirmed, best key after %v (not confirmed)\n", result.ElapsedTime)
+						}
 						fmt.Printf("Key: %s\n", result.Key)
+						if result.Period > 1 {
+							fmt.Printf("Period: %d\n", result.Period)
+						}
+						fmt.Printf("Seed: %d\n", result.Seed)
 						fmt.Printf("English Word Score %2.2f\n\n", 100.0*result.PercentEnglish)
 
 						fmt.Printf("Raw Plaintext:\n%s\n\n", result.Plaintext)
@@ -73,19 +301,139 @@ func main() {
 						for _, word := range result.SegmentedText {
 							fmt.Printf("%s ", word)
 						}
-						fmt.Printf("\n")
+						fmt.Printf("\n\n")
+
+						cmdutil.PrintDigitPlaintext(result.SegmentedText, reduction)
+						cmdutil.PrintCleanedPlaintext([]byte(result.Plaintext), crackOpts.SeparatorLetter, cipher.CLASSIC_CONVENTION, result.Period, layout, reduction)
 					} else {
-						fmt.Printf("%f, %f, %s", score.ScoreTextFast([]byte(result.Plaintext), 'X'), result.PercentEnglish, result.Plaintext)
+						if !result.Confirmed {
+							fmt.Printf("not confirmed, ")
+						}
+						fmt.Printf("%f, %f, %s", result.Score, result.PercentEnglish, result.Plaintext)
 						for _, word := range result.SegmentedText {
 							fmt.Printf("%s ", word)
 						}
 						// fmt.Printf("%-4.4f ", score.ScoreTextFast([]byte(result.Plaintext), 'X'))
 						// fmt.Printf("%s\n", result.Plaintext)
+						fmt.Printf(", %s", cmdutil.CleanedPlaintext([]byte(result.Plaintext), crackOpts.SeparatorLetter, cipher.CLASSIC_CONVENTION, result.Period, layout, reduction))
+						if digits, found := cmdutil.DigitPlaintext(result.SegmentedText, reduction); found {
+							fmt.Printf(", %s", digits)
+						}
+					}
+
+					if len(result.Candidates) > 0 {
+						fmt.Printf("\n\n")
+						cmdutil.PrintCandidates(result.Candidates)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:    "recover-key",
+				Aliases: []string{"r"},
+				Usage:   "Recover the key from the ciphertext given to stdin and its known plaintext",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "plaintext",
+						Aliases:     []string{"p"},
+						Destination: &plaintext,
+						Usage:       "The known `PLAINTEXT` of the ciphertext",
+						Required:    true,
+					},
+					&cli.StringFlag{
+						Name:        "file",
+						Aliases:     []string{"f"},
+						Destination: &filepath,
+						Usage:       "Load ciphertext from `FILE` instead",
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					if !layout.IsStandard() {
+						return fmt.Errorf("Keys can only be recovered on 5x5 grids of letters")
+					}
+
+					err, text := cmdutil.GatherInput(filepath)
+					if err != nil {
+						return err
+					}
+
+					err, ciphertext := cmdutil.ValidateAndTransformCiphertext(text, reduction.Excluded)
+					if err != nil {
+						return err
+					}
+
+					err, plainText := cmdutil.ValidateAndTransformPlaintext(plaintext, reduction.Excluded, reduction.Replacement, 'X')
+					if err != nil {
+						return err
+					}
+
+					recovery, err := crack.RecoverKey([]byte(plainText), []byte(ciphertext), byte(reduction.Excluded))
+					if err != nil {
+						return err
+					}
+
+					fmt.Printf("Key: %s\n\n", recovery.Key)
+					cmdutil.PrintGrid(recovery.Key, recovery.Ambiguous)
+
+					if recovery.Determined {
+						fmt.Printf("\nThe key is fully determined, up to rotation\n")
+					} else {
+						fmt.Printf("\nThe key is not fully determined, %d cells are ambiguous across %d grids", len(recovery.Ambiguous), recovery.Solutions)
+						if recovery.Exhausted {
+							fmt.Printf(" (search stopped early)")
+						}
+						fmt.Printf("\n")
+					}
+
+					if recovery.Verified {
+						fmt.Printf("Verified, re-encrypting the plaintext gives the ciphertext\n")
+					} else {
+						fmt.Printf("Not verified, re-encrypting the plaintext does not give the ciphertext\n")
 					}
 
 					return nil
 				},
 			},
+			{
+				Name:    "keyword",
+				Aliases: []string{"w"},
+				Usage:   "Recover the keyword a cracked key grid was built from",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "key",
+						Aliases:     []string{"k"},
+						Destination: &key,
+						Usage:       "The cracked 25 letter `KEY` grid",
+						Required:    true,
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					if !layout.IsStandard() {
+						return fmt.Errorf("Keywords can only be recovered from 5x5 grids of letters")
+					}
+
+					err, keyGrid := cmdutil.ValidateAndTransformGrid(key, reduction.Excluded, reduction.Replacement)
+					if err != nil {
+						return err
+					}
+
+					recovery, err := crack.RecoverKeyword(keyGrid, byte(reduction.Replacement))
+					if err != nil {
+						return err
+					}
+
+					if recovery.Phrase != "" {
+						fmt.Printf("Keyword: %s\n", recovery.Phrase)
+					} else {
+						fmt.Printf("Keyword: %s (not in the dictionary)\n", recovery.Keyword)
+					}
+					fmt.Printf("Key: %s\n\n", recovery.Key)
+					cmdutil.PrintGrid(recovery.Key, nil)
+
+					return nil
+				},
+			},
 			{
 				Name:    "decrypt",
 				Aliases: []string{"d"},
@@ -98,6 +446,34 @@ func main() {
 						Usage:       "Decrypt with `KEY`",
 						Required:    true,
 					},
+					&cli.StringFlag{
+						Name:        "key2",
+						Destination: &key2,
+						Usage:       "Decrypt with `KEY` as the second grid, for ciphers taking two",
+					},
+					&cli.IntFlag{
+						Name:        "period",
+						Destination: &period,
+						Usage:       "Seriate the digraphs down columns of rows of `N` letters, as Seriated Playfair does",
+					},
+					&cli.StringFlag{
+						Name:        "convention",
+						Destination: &conventionName,
+						Value:       "classic",
+						Usage:       "Take out the nulls the `CONVENTION` classic, aca, qx or split-all split doubled letters with",
+					},
+					&cli.StringFlag{
+						Name:        "cipher",
+						Destination: &cipherName,
+						Value:       "playfair",
+						Usage:       "Use the `CIPHER` playfair, twosquare, twosquare-vertical, foursquare or doubleplayfair",
+					},
+					&cli.StringFlag{
+						Name:        "rules",
+						Destination: &rulesName,
+						Value:       "standard",
+						Usage:       "Map Playfair digraphs by the `RULES` standard, left-up, opposite-corner or same-row-unchanged",
+					},
 					&cli.StringFlag{
 						Name:        "file",
 						Aliases:     []string{"f"},
@@ -111,26 +487,62 @@ func main() {
 						return err
 					}
 
-					err, ciphertext := cmdutil.ValidateAndTransformCiphertext(text, 'J')
+					cipherType, err := cipher.ParseType(cipherName)
+					if err != nil {
+						return err
+					}
+
+					err, rules := cmdutil.ValidateAndTransformRules(rulesName, cipherType)
+					if err != nil {
+						return err
+					}
+
+					var sealed cmdutil.Envelope
+					isEnvelope := cmdutil.IsEnvelope(text)
+					if isEnvelope {
+						err, sealed = cmdutil.ValidateAndTransformEnvelope(text)
+						if err != nil {
+							return err
+						}
+						text = sealed.Ciphertext
+					}
+
+					err, ciphertext := cmdutil.ValidateAndTransformLayoutCiphertext(text, layout, cipherType.AllowsDoubles(), period)
+					if err != nil {
+						return err
+					}
+
+					convention, err := cipher.ParseConvention(conventionName)
 					if err != nil {
 						return err
 					}
 
-					var validKey [25]byte
-					err, validKey = cmdutil.ValidateAndTransformKey(key, 'J')
+					err, validKeys := cmdutil.ValidateAndTransformKeys([]string{key, key2}, cipherType, layout, reduction, true)
 					if err != nil {
 						return err
 					}
 
 					fmt.Printf("Decrypting Text:\n%s\n\n", text)
-					fmt.Printf("With Key: %s\n\n", key)
+					cmdutil.PrintKeys(validKeys, cipherType)
 
-					plaintext := cipher.PlayfairDecrypt([]byte(ciphertext), validKey, 'J')
+					plaintext := cipherType.Decrypt([]byte(ciphertext), validKeys, period, rules)
 
 					fmt.Printf("Raw Plaintext:\n%s\n\n", plaintext)
 
 					fmt.Printf("Segmented Plaintext:\n")
-					cmdutil.PrintSegmentedPlaintext(plaintext, 'X')
+					words := cmdutil.PrintSegmentedPlaintext(plaintext, 'X', convention)
+					fmt.Printf("\n\n")
+
+					cmdutil.PrintDigitPlaintext(words, reduction)
+					cmdutil.PrintCleanedPlaintext(plaintext, 'X', convention, period, layout, reduction)
+
+					if isEnvelope {
+						err, restored := sealed.Restore(plaintext, reduction)
+						if err != nil {
+							return err
+						}
+						fmt.Printf("\nRestored Plaintext:\n%s\n", restored)
+					}
 
 					return nil
 				},
@@ -146,6 +558,39 @@ func main() {
 						Destination: &key,
 						Usage:       "Encrypt with `KEY`",
 					},
+					&cli.StringFlag{
+						Name:        "key2",
+						Destination: &key2,
+						Usage:       "Encrypt with `KEY` as the second grid, for ciphers taking two",
+					},
+					&cli.IntFlag{
+						Name:        "period",
+						Destination: &period,
+						Usage:       "Seriate the digraphs down columns of rows of `N` letters, as Seriated Playfair does",
+					},
+					&cli.StringFlag{
+						Name:        "convention",
+						Destination: &conventionName,
+						Value:       "classic",
+						Usage:       "Split doubled letters by the `CONVENTION` classic, aca, qx or split-all",
+					},
+					&cli.BoolFlag{
+						Name:        "envelope",
+						Destination: &envelope,
+						Usage:       "Write the ciphertext in an envelope with the format mask of the plaintext, so decrypt restores it exactly",
+					},
+					&cli.StringFlag{
+						Name:        "cipher",
+						Destination: &cipherName,
+						Value:       "playfair",
+						Usage:       "Use the `CIPHER` playfair, twosquare, twosquare-vertical, foursquare or doubleplayfair",
+					},
+					&cli.StringFlag{
+						Name:        "rules",
+						Destination: &rulesName,
+						Value:       "standard",
+						Usage:       "Map Playfair digraphs by the `RULES` standard, left-up, opposite-corner or same-row-unchanged",
+					},
 					&cli.StringFlag{
 						Name:        "file",
 						Aliases:     []string{"f"},
@@ -159,23 +604,46 @@ func main() {
 						return err
 					}
 
-					err, plainText := cmdutil.ValidateAndTransformPlaintext(text, 'J', 'I', 'X')
+					cipherType, err := cipher.ParseType(cipherName)
+					if err != nil {
+						return err
+					}
+
+					err, rules := cmdutil.ValidateAndTransformRules(rulesName, cipherType)
 					if err != nil {
 						return err
 					}
 
-					var validKey [25]byte
-					err, validKey = cmdutil.ValidateAndTransformKey(key, 'J')
+					convention, err := cipher.ParseConvention(conventionName)
+					if err != nil {
+						return err
+					}
+
+					var plainText, mask string
+					if envelope {
+						err, plainText, mask = cmdutil.ValidateAndTransformEnvelopePlaintext(text, layout, reduction, 'X', convention, period)
+					} else {
+						err, plainText = cmdutil.ValidateAndTransformLayoutPlaintext(text, layout, reduction, 'X', convention, period)
+					}
+					if err != nil {
+						return err
+					}
+
+					err, validKeys := cmdutil.ValidateAndTransformKeys([]string{key, key2}, cipherType, layout, reduction, false)
 					if err != nil {
 						return err
 					}
 
 					fmt.Printf("Encrypting Text:\n%s\n\n", text)
-					fmt.Printf("With Key: %s\n\n", key)
+					cmdutil.PrintKeys(validKeys, cipherType)
 
-					ciphertext := cipher.PlayfairEncrypt([]byte(plainText), validKey, 'J')
+					ciphertext := cipherType.Encrypt([]byte(plainText), validKeys, period, rules)
 
-					fmt.Printf("Ciphertext:\n%s\n", ciphertext)
+					if envelope {
+						fmt.Printf("Ciphertext:\n%s\n", cmdutil.Envelope{Ciphertext: string(ciphertext), Mask: mask})
+					} else {
+						fmt.Printf("Ciphertext:\n%s\n", ciphertext)
+					}
 
 					return nil
 				},
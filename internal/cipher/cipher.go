@@ -4,7 +4,46 @@ import (
 	"math/rand"
 )
 
-func GenerateRandomKey(excludedLetter byte) [25]byte {
+// PinnedCells holds the letter known to sit in each cell of a key, zero for
+// the cells that are free to change.
+type PinnedCells [25]byte
+
+// FreeCells lists the cells not pinned to a letter.
+func (pins *PinnedCells) FreeCells() []int {
+	var free []int
+	for i, l := range pins {
+		if l == 0 {
+			free = append(free, i)
+		}
+	}
+	return free
+}
+
+// IsPinned reports whether the letter l is pinned to some cell.
+func (pins *PinnedCells) IsPinned(l byte) bool {
+	for _, pinned := range pins {
+		if pinned == l {
+			return true
+		}
+	}
+	return false
+}
+
+// KeywordKey builds the grid for keyword the usual way, its distinct letters
+// in order followed by the rest of the alphabet. Anything other than A-Z and
+// the excluded letter are skipped, lower case letters are upper cased.
+func KeywordKey(keyword string, excludedLetter byte) [25]byte {
+	return [25]byte(StandardLayout(excludedLetter).KeywordKey(keyword).Cells())
+}
+
+// RotateKey moves every row of key up by rows and every column left by cols,
+// wrapping around. All 25 rotations of a key encrypt the same way.
+func RotateKey(key [25]byte, rows int, cols int) [25]byte {
+	return [25]byte(NewKey(key, 0).Rotate(rows, cols).Cells())
+}
+
+// GenerateRandomKey returns a shuffled grid of every letter but excludedLetter.
+func GenerateRandomKey(rng *rand.Rand, excludedLetter byte) [25]byte {
 	var key [25]byte
 	idx := 0
 	for l := byte('A'); l <= 'Z'; l++ {
@@ -13,156 +52,147 @@ func GenerateRandomKey(excludedLetter byte) [25]byte {
 			idx++
 		}
 	}
-	rand.Shuffle(25, func(i, j int) { key[i], key[j] = key[j], key[i] })
+	rng.Shuffle(25, func(i, j int) { key[i], key[j] = key[j], key[i] })
 
 	return key
 }
 
-func PermuteKey(key [25]byte, excludedLetter byte) [25]byte {
-	if len(key) != 25 {
-		panic("Key length must be 25")
+// GeneratePinnedKey returns a grid with every pinned letter in its cell and
+// the remaining letters shuffled into the free cells.
+func GeneratePinnedKey(rng *rand.Rand, excludedLetter byte, pins *PinnedCells) [25]byte {
+	var letters []byte
+	for l := byte('A'); l <= 'Z'; l++ {
+		if l != excludedLetter && !pins.IsPinned(l) {
+			letters = append(letters, l)
+		}
+	}
+	rng.Shuffle(len(letters), func(i, j int) { letters[i], letters[j] = letters[j], letters[i] })
+
+	key := [25]byte(*pins)
+	for i := range key {
+		if key[i] == 0 {
+			key[i], letters = letters[0], letters[1:]
+		}
 	}
-	r := rand.Uint32() % 100
+
+	return key
+}
+
+// PermuteKey returns a random neighbour of key, all randomness is drawn from
+// rng so that a seeded rng reproduces the same sequence of keys.
+func PermuteKey(rng *rand.Rand, key [25]byte, excludedLetter byte) [25]byte {
+	return PermutePinnedKey(rng, key, excludedLetter, nil)
+}
+
+// PermutePinnedKey is PermuteKey for a key whose pinned cells must not move,
+// pins may be nil when nothing is pinned.
+func PermutePinnedKey(rng *rand.Rand, key [25]byte, excludedLetter byte, pins *PinnedCells) [25]byte {
+	permuteCells(rng, key[:], 5, 5, pins)
+	return key
+}
+
+// permuteCells changes cells, a rows by cols grid, into a random neighbour in
+// place. Pins only apply to 5x5 grids and may be nil.
+func permuteCells(rng *rand.Rand, cells []byte, rows int, cols int, pins *PinnedCells) {
+	r := rng.Uint32() % 100
 	if r < 2 {
-		for i := 0; i < rand.Intn(25)+1; i++ {
-			key = swapChars(key)
+		for i := 0; i < rng.Intn(len(cells))+1; i++ {
+			swapChars(rng, cells, pins)
 		}
 	} else if r < 5 {
-		key = GenerateRandomKey(excludedLetter)
+		shuffleCells(rng, cells, pins)
 	} else if r < 10 {
-		key = swapRows(key)
+		swapRows(rng, cells, rows, cols, pins)
 	} else if r < 16 {
-		key = swapCols(key)
+		swapCols(rng, cells, rows, cols, pins)
 	} else {
-		key = swapChars(key)
+		swapChars(rng, cells, pins)
 	}
-	return key
 }
 
-func swapRows(key [25]byte) [25]byte {
-	row1, row2 := rand.Intn(5), rand.Intn(5)
-	base1, base2 := row1*5, row2*5
-	for i := 0; i < 5; i++ {
-		key[base1+i], key[base2+i] = key[base2+i], key[base1+i]
+// freeLines returns the count lines of length cells without any pinned
+// cell, cell i of line holding line*stride+i*step.
+func freeLines(pins *PinnedCells, count int, length int, stride int, step int) []int {
+	var free []int
+	for line := 0; line < count; line++ {
+		pinned := false
+		for i := 0; i < length; i++ {
+			pinned = pinned || pins[line*stride+i*step] != 0
+		}
+		if !pinned {
+			free = append(free, line)
+		}
 	}
-	return key
+	return free
 }
 
-func swapCols(key [25]byte) [25]byte {
-	col1, col2 := rand.Intn(5), rand.Intn(5)
-	for i := 0; i < 5; i++ {
-		rowBase := i * 5
-		key[rowBase+col1], key[rowBase+col2] = key[rowBase+col2], key[rowBase+col1]
+// shuffleCells moves every free letter to a random free cell, giving a new
+// random key.
+func shuffleCells(rng *rand.Rand, cells []byte, pins *PinnedCells) {
+	if pins == nil {
+		rng.Shuffle(len(cells), func(i, j int) { cells[i], cells[j] = cells[j], cells[i] })
+		return
 	}
-	return key
-}
 
-func swapChars(key [25]byte) [25]byte {
-	idx1, idx2 := rand.Intn(25), rand.Intn(25)
-	key[idx1], key[idx2] = key[idx2], key[idx1]
-	return key
+	free := pins.FreeCells()
+	rng.Shuffle(len(free), func(i, j int) {
+		cells[free[i]], cells[free[j]] = cells[free[j]], cells[free[i]]
+	})
 }
 
-// PlayfairDecrypt decrypts the ciphertext using the given key.
-func PlayfairDecrypt(ciphertext []byte, key [25]byte, excludedLetter byte) []byte {
-	var position [26]int
-	position[excludedLetter-'A'] = -1
-	for i, char := range key {
-		position[char-'A'] = i
-	}
-
-	ctlen := len(ciphertext)
-	decryptedText := make([]byte, ctlen)
-
-	for i := 1; i < ctlen; i += 2 {
-		char1, char2 := ciphertext[i-1], ciphertext[i]
-		pos1 := position[char1-'A']
-		pos2 := position[char2-'A']
-		row1, col1 := pos1/5, pos1%5
-		row2, col2 := pos2/5, pos2%5
-
-		if row1 == row2 {
-			// Same row: shift left
-			newCol1 := col1 - 1
-			if newCol1 < 0 {
-				newCol1 = 4
-			}
-			newCol2 := col2 - 1
-			if newCol2 < 0 {
-				newCol2 = 4
-			}
-			decryptedText[i-1] = key[row1*5+newCol1]
-			decryptedText[i] = key[row2*5+newCol2]
-		} else if col1 == col2 {
-			// Same column: shift up
-			newRow1 := row1 - 1
-			if newRow1 < 0 {
-				newRow1 = 4
-			}
-			newRow2 := row2 - 1
-			if newRow2 < 0 {
-				newRow2 = 4
-			}
-			decryptedText[i-1] = key[newRow1*5+col1]
-			decryptedText[i] = key[newRow2*5+col2]
-		} else {
-			// Rectangle swap
-			decryptedText[i-1] = key[row1*5+col2]
-			decryptedText[i] = key[row2*5+col1]
+func swapRows(rng *rand.Rand, cells []byte, rows int, cols int, pins *PinnedCells) {
+	row1, row2 := rng.Intn(rows), rng.Intn(rows)
+	if pins != nil {
+		free := freeLines(pins, rows, cols, cols, 1)
+		if len(free) < 2 {
+			swapChars(rng, cells, pins)
+			return
 		}
+		row1, row2 = free[rng.Intn(len(free))], free[rng.Intn(len(free))]
 	}
 
-	return decryptedText
+	base1, base2 := row1*cols, row2*cols
+	for i := 0; i < cols; i++ {
+		cells[base1+i], cells[base2+i] = cells[base2+i], cells[base1+i]
+	}
 }
 
-// PlayfairEncrypt encrypts the plaintext using the given key.
-func PlayfairEncrypt(plaintext []byte, key [25]byte, excludedLetter byte) []byte {
-	var position [26]int
-	position[excludedLetter-'A'] = -1
-	for i, char := range key {
-		position[char-'A'] = i
+func swapCols(rng *rand.Rand, cells []byte, rows int, cols int, pins *PinnedCells) {
+	col1, col2 := rng.Intn(cols), rng.Intn(cols)
+	if pins != nil {
+		free := freeLines(pins, cols, rows, 1, cols)
+		if len(free) < 2 {
+			swapChars(rng, cells, pins)
+			return
+		}
+		col1, col2 = free[rng.Intn(len(free))], free[rng.Intn(len(free))]
 	}
 
-	ptlen := len(plaintext)
-	encryptedText := make([]byte, ptlen)
-
-	for i := 1; i < ptlen; i += 2 {
-		char1, char2 := plaintext[i-1], plaintext[i]
-		pos1 := position[char1-'A']
-		pos2 := position[char2-'A']
-		row1, col1 := pos1/5, pos1%5
-		row2, col2 := pos2/5, pos2%5
-
-		if row1 == row2 {
-			// Same row: shift right
-			newCol1 := col1 + 1
-			if newCol1 == 5 {
-				newCol1 = 0
-			}
-			newCol2 := col2 + 1
-			if newCol2 == 5 {
-				newCol2 = 0
-			}
-			encryptedText[i-1] = key[row1*5+newCol1]
-			encryptedText[i] = key[row2*5+newCol2]
-		} else if col1 == col2 {
-			// Same column: shift down
-			newRow1 := row1 + 1
-			if newRow1 == 5 {
-				newRow1 = 0
-			}
-			newRow2 := row2 + 1
-			if newRow2 == 5 {
-				newRow2 = 0
-			}
-			encryptedText[i-1] = key[newRow1*5+col1]
-			encryptedText[i] = key[newRow2*5+col2]
-		} else {
-			// Rectangle: swap columns
-			encryptedText[i-1] = key[row1*5+col2]
-			encryptedText[i] = key[row2*5+col1]
+	for i := 0; i < rows; i++ {
+		rowBase := i * cols
+		cells[rowBase+col1], cells[rowBase+col2] = cells[rowBase+col2], cells[rowBase+col1]
+	}
+}
+
+func swapChars(rng *rand.Rand, cells []byte, pins *PinnedCells) {
+	idx1, idx2 := rng.Intn(len(cells)), rng.Intn(len(cells))
+	if pins != nil {
+		free := pins.FreeCells()
+		if len(free) < 2 {
+			return
 		}
+		idx1, idx2 = free[rng.Intn(len(free))], free[rng.Intn(len(free))]
 	}
 
-	return encryptedText
+	cells[idx1], cells[idx2] = cells[idx2], cells[idx1]
+}
+
+// PlayfairDecrypt decrypts the ciphertext using the given key.
+func PlayfairDecrypt(ciphertext []byte, key [25]byte, excludedLetter byte) []byte {
+	return NewKey(key, excludedLetter).Decrypt(ciphertext)
+}
+
+// PlayfairEncrypt encrypts the plaintext using the given key.
+func PlayfairEncrypt(plaintext []byte, key [25]byte, excludedLetter byte) []byte {
+	return NewKey(key, excludedLetter).Encrypt(plaintext)
 }
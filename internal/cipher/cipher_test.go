@@ -2,6 +2,7 @@ package cipher
 
 import (
 	"fmt"
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -56,6 +57,73 @@ func TestPlayfairEncrypt(t *testing.T) {
 	}
 }
 
+func TestKeywordKey(t *testing.T) {
+	tests := []struct {
+		keyword string
+		key     string
+	}{
+		{keyword: "playfair example", key: "PLAYFIREXMBCDGHKNOQSTUVWZ"},
+		{keyword: "Jumbo Jet", key: "UMBOETACDFGHIKLNPQRSVWXYZ"},
+		{keyword: "", key: "ABCDEFGHIKLMNOPQRSTUVWXYZ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.keyword, func(t *testing.T) {
+			key := KeywordKey(tt.keyword, 'J')
+			assert.Equal(t, tt.key, string(key[:]))
+		})
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	key := KeywordKey("playfair example", 'J')
+	rotated := RotateKey(key, 1, 2)
+	assert.Equal(t, "EXMIRDGHBCOQSKNVWZTUAYFPL", string(rotated[:]))
+	assert.Equal(t, key, RotateKey(rotated, 4, 3))
+
+	plaintext := []byte("HIDETHEGOLDINTHETREXESTUMP")
+	assert.Equal(t, PlayfairEncrypt(plaintext, key, 'J'), PlayfairEncrypt(plaintext, rotated, 'J'))
+}
+
+func TestPermuteKeySeeded(t *testing.T) {
+	walk := func(seed int64) [][25]byte {
+		rng := rand.New(rand.NewSource(seed))
+		key := GenerateRandomKey(rng, 'J')
+		keys := [][25]byte{key}
+		for i := 0; i < 1000; i++ {
+			key = PermuteKey(rng, key, 'J')
+			keys = append(keys, key)
+		}
+		return keys
+	}
+
+	assert.Equal(t, walk(42), walk(42))
+	assert.NotEqual(t, walk(42), walk(43))
+}
+
+func TestPermutePinnedKey(t *testing.T) {
+	var pins PinnedCells
+	pins[0], pins[1], pins[7], pins[24] = 'P', 'L', 'A', 'Y'
+
+	rng := rand.New(rand.NewSource(42))
+	key := GeneratePinnedKey(rng, 'J', &pins)
+	for i := 0; i < 10000; i++ {
+		key = PermutePinnedKey(rng, key, 'J', &pins)
+
+		letters := make(map[byte]bool)
+		for _, l := range key {
+			letters[l] = true
+		}
+		assert.Len(t, letters, 25)
+		assert.NotContains(t, letters, byte('J'))
+		for cell, l := range pins {
+			if l != 0 {
+				assert.Equal(t, l, key[cell])
+			}
+		}
+	}
+}
+
 func BenchmarkPlayfairCrack(b *testing.B) {
 	ciphertext := []byte("WATCHINGASUNSETOVERTHEOCEANISONEOFNATURESGREATESTSPECTACLESASTHESUNDIPSLOWERINTHESKYTHECOLORSXSHIFTFROMBRIGHTORANGESANDPINKSTODEXEPXPURPLESANDBLUESREFLECTINGOFXFTHESURFACEOFTHEWATERTHEWAVESCONTINUETHEIRSTEADYRHYTHMCRASHINGAGAINSTXTHESHOREASTHELASTRAYSOFSUNLIGHTDISAPXPEARBEYONDTHEHORIZONITSAMOMENTOFQ")
 	key := stringTo25Byte("RSBQLVECTIAWPNGKFYOZHXDMU")
@@ -0,0 +1,101 @@
+package cipher
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Convention is a way of preparing plaintext letters for Playfair: which
+// doubled letters are split and by what, and what pads the text to an even
+// length. Every convention splits with a separator letter, usually X, and
+// pads after a last separator with a second null, which all but the classic
+// one also split a doubled separator with.
+type Convention int
+
+const (
+	// CLASSIC_CONVENTION is Wheatstone's: the separator splits doubled
+	// letters falling in one digraph and pads the text, Q padding after a
+	// last separator. A doubled separator cannot be split.
+	CLASSIC_CONVENTION Convention = iota
+	// ACA_CONVENTION is the classic one with Z splitting a doubled separator
+	// and padding after a last separator.
+	ACA_CONVENTION
+	// QX_CONVENTION is the classic one with Q splitting a doubled separator
+	// and padding after a last separator.
+	QX_CONVENTION
+	// SPLIT_ALL_CONVENTION splits every doubled letter of the text, whether
+	// or not it falls in one digraph, Q splitting a doubled separator.
+	SPLIT_ALL_CONVENTION
+)
+
+var conventionNames = []string{
+	CLASSIC_CONVENTION:   "classic",
+	ACA_CONVENTION:       "aca",
+	QX_CONVENTION:        "qx",
+	SPLIT_ALL_CONVENTION: "split-all",
+}
+
+// ParseConvention reads the name of a convention, as returned by
+// Convention.String.
+func ParseConvention(name string) (Convention, error) {
+	for convention, conventionName := range conventionNames {
+		if strings.EqualFold(name, conventionName) {
+			return Convention(convention), nil
+		}
+	}
+	return CLASSIC_CONVENTION, fmt.Errorf("Conventions must be one of %s, got %s", strings.Join(conventionNames, ", "), name)
+}
+
+func (convention Convention) String() string {
+	return conventionNames[convention]
+}
+
+// Null returns the letter splitting a doubled sep, or padding after a last
+// sep, under convention, the classic convention only padding with it. Z
+// stands in for Q, or the other way around, when sep is the usual null
+// itself.
+func (convention Convention) Null(sep byte) byte {
+	var null, other byte
+	switch convention {
+	case ACA_CONVENTION:
+		null, other = 'Z', 'Q'
+	default:
+		null, other = 'Q', 'Z'
+	}
+	if null == sep {
+		return other
+	}
+	return null
+}
+
+// split returns the letter splitting a doubled l, or padding after a last l.
+func (convention Convention) split(l byte, sep byte) byte {
+	if l == sep {
+		return convention.Null(sep)
+	}
+	return sep
+}
+
+// Prepare splits the doubled letters of letters under convention and pads
+// them to an even length, giving plaintext ready for PlayfairEncrypt. It
+// fails on a doubled sep under the classic convention.
+func (convention Convention) Prepare(letters []byte, sep byte) ([]byte, error) {
+	prepared := make([]byte, 0, len(letters)+len(letters)/2+1)
+	for _, l := range letters {
+		n := len(prepared)
+		aligned := n%2 == 1 || convention == SPLIT_ALL_CONVENTION
+		if n > 0 && aligned && prepared[n-1] == l {
+			if convention == CLASSIC_CONVENTION && l == sep {
+				return nil, fmt.Errorf("The %s convention cannot split the doubled separator %c%c, try another convention", convention, l, l)
+			}
+			prepared = append(prepared, convention.split(l, sep))
+		}
+		prepared = append(prepared, l)
+	}
+
+	if n := len(prepared); n%2 != 0 {
+		prepared = append(prepared, convention.split(prepared[n-1], sep))
+	}
+
+	return prepared, nil
+}
@@ -0,0 +1,60 @@
+package cipher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConventionPrepare(t *testing.T) {
+	key, err := ParseKey("playfair example", 'J')
+	assert.NoError(t, err)
+
+	tests := []struct {
+		convention Convention
+		letters    string
+		prepared   string
+	}{
+		{convention: CLASSIC_CONVENTION, letters: "BALLOON", prepared: "BALXLOON"},
+		{convention: CLASSIC_CONVENTION, letters: "BOOKKEEPER", prepared: "BOOKKEEPER"},
+		{convention: CLASSIC_CONVENTION, letters: "TAXX"},
+		{convention: CLASSIC_CONVENTION, letters: "TAX", prepared: "TAXQ"},
+		{convention: ACA_CONVENTION, letters: "TAXX", prepared: "TAXZXZ"},
+		{convention: QX_CONVENTION, letters: "TAXX", prepared: "TAXQXQ"},
+		{convention: QX_CONVENTION, letters: "BALLOON", prepared: "BALXLOON"},
+		{convention: SPLIT_ALL_CONVENTION, letters: "BOOKKEEPER", prepared: "BOXOKXKEXEPERX"},
+		{convention: SPLIT_ALL_CONVENTION, letters: "XXX", prepared: "XQXQXQ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.convention.String()+"/"+tt.letters, func(t *testing.T) {
+			prepared, err := tt.convention.Prepare([]byte(tt.letters), 'X')
+			if tt.prepared == "" {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.prepared, string(prepared))
+			for i := 1; i < len(prepared); i += 2 {
+				assert.NotEqual(t, prepared[i-1], prepared[i])
+			}
+			assert.Equal(t, prepared, key.Decrypt(key.Encrypt(prepared)))
+		})
+	}
+}
+
+func TestParseConvention(t *testing.T) {
+	for _, convention := range []Convention{CLASSIC_CONVENTION, ACA_CONVENTION, QX_CONVENTION, SPLIT_ALL_CONVENTION} {
+		parsed, err := ParseConvention(convention.String())
+		assert.NoError(t, err)
+		assert.Equal(t, convention, parsed)
+	}
+
+	_, err := ParseConvention("wheatstone-nulls")
+	assert.Error(t, err)
+
+	// The null never clashes with the separator
+	assert.Equal(t, byte('Q'), QX_CONVENTION.Null('X'))
+	assert.Equal(t, byte('Z'), QX_CONVENTION.Null('Q'))
+	assert.Equal(t, byte('Q'), CLASSIC_CONVENTION.Null('X'))
+}
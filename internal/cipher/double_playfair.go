@@ -0,0 +1,48 @@
+package cipher
+
+// DoublePlayfairEncrypt encrypts plaintext with the German Double Playfair,
+// or Doppelkasten, its two squares side by side with first on the left. Every
+// digraph is mapped twice by doubleBox, the first symbol looked up in first
+// and the second in second both times. Both keys must share a layout.
+func DoublePlayfairEncrypt(plaintext []byte, first Key, second Key) []byte {
+	return doubleBox(doubleBox(plaintext, first, second, 1), first, second, 1)
+}
+
+// DoublePlayfairDecrypt decrypts ciphertext encrypted by DoublePlayfairEncrypt
+// with the same keys, which must be of even length.
+func DoublePlayfairDecrypt(ciphertext []byte, first Key, second Key) []byte {
+	return doubleBox(doubleBox(ciphertext, first, second, -1), first, second, -1)
+}
+
+// doubleBox maps every digraph of text once, as Horizontal Two-Square does
+// with left on the left and right on the right, but without ever leaving a
+// digraph in plain sight: one whose symbols share a row is reversed and
+// then moved by shift cells along it, 1 being right. Encrypting looks the
+// first symbol up in left and writes it to right, so decrypting, with a
+// shift of -1, looks it up in right.
+func doubleBox(text []byte, left Key, right Key, shift int) []byte {
+	in1, in2, out1, out2 := left, right, right, left
+	if shift < 0 {
+		in1, in2, out1, out2 = right, left, left, right
+	}
+	cols := in1.cols
+	mapped := make([]byte, len(text))
+
+	for i := 1; i < len(text); i += 2 {
+		char1, char2 := text[i-1], text[i]
+		row1, col1 := int(in1.row[char1]), int(in1.col[char1])
+		row2, col2 := int(in2.row[char2]), int(in2.col[char2])
+
+		if row1 == row2 {
+			// Same row: reverse and shift along it
+			mapped[i-1] = out1.grid[row1*cols+wrap(col2+shift, cols)]
+			mapped[i] = out2.grid[row2*cols+wrap(col1+shift, cols)]
+		} else {
+			// Rectangle: swap columns
+			mapped[i-1] = out1.grid[row1*cols+col2]
+			mapped[i] = out2.grid[row2*cols+col1]
+		}
+	}
+
+	return mapped
+}
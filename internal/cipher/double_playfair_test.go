@@ -0,0 +1,34 @@
+package cipher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoublePlayfair(t *testing.T) {
+	// DOPEL    WEHRM
+	// KASTN    ACTBD
+	// BCFGH    FGIKL
+	// IMQRU    NOPQS
+	// VWXYZ    UVXYZ
+	left, err := ParseKey("doppelkasten", 'J')
+	assert.NoError(t, err)
+	right, err := ParseKey("wehrmacht", 'J')
+	assert.NoError(t, err)
+
+	// Worked by hand: AN makes the rectangle A, M then D, O, while IN shares
+	// a row, taking the neighbours right of N and I to OM, then WP
+	plaintext := []byte("ANGRIFFBEGINNTUMVIERUHRX")
+	ciphertext := DoublePlayfairEncrypt(plaintext, left, right)
+	assert.Equal(t, "DOCDDKDIEGWPLCDFYSSCMFRX", string(ciphertext))
+	assert.Equal(t, plaintext, DoublePlayfairDecrypt(ciphertext, left, right))
+	assert.Equal(t, ciphertext, DOUBLE_PLAYFAIR.Encrypt(plaintext, [2]Key{left, right}, 0, STANDARD_RULES))
+	assert.True(t, DOUBLE_PLAYFAIR.AllowsDoubles())
+
+	// The order of the squares matters, moving their rows together and their
+	// columns apart does not
+	assert.NotEqual(t, ciphertext, DoublePlayfairEncrypt(plaintext, right, left))
+	assert.Equal(t, ciphertext, DoublePlayfairEncrypt(plaintext, left.Rotate(2, 1), right.Rotate(2, 3)))
+	assert.NotEqual(t, ciphertext, DoublePlayfairEncrypt(plaintext, left.Rotate(2, 1), right.Rotate(1, 3)))
+}
@@ -0,0 +1,19 @@
+package cipher
+
+// FourSquareEncrypt encrypts plaintext with the Four-Square cipher. The first
+// symbol of every digraph is found in the plain square at the top left and the
+// second in the plain square at the bottom right, their rectangle giving the
+// symbols in first at the top right and second at the bottom left. Plain
+// squares hold the symbols of the keys in order. Both keys must share a
+// layout.
+func FourSquareEncrypt(plaintext []byte, first Key, second Key) []byte {
+	plain := first.plain()
+	return twoSquare(plaintext, plain, plain, first, second)
+}
+
+// FourSquareDecrypt decrypts ciphertext encrypted by FourSquareEncrypt with
+// the same keys, which must be of even length.
+func FourSquareDecrypt(ciphertext []byte, first Key, second Key) []byte {
+	plain := first.plain()
+	return twoSquare(ciphertext, first, second, plain, plain)
+}
@@ -0,0 +1,28 @@
+package cipher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFourSquare(t *testing.T) {
+	first, err := ParseKey("example", 'Q')
+	assert.NoError(t, err)
+	second, err := ParseKey("keyword", 'Q')
+	assert.NoError(t, err)
+
+	plaintext := []byte("HELPMEOBIWANKENOBI")
+	ciphertext := FourSquareEncrypt(plaintext, first, second)
+	assert.Equal(t, "FYGMKYHOBXMFKKKIMD", string(ciphertext))
+	assert.Equal(t, plaintext, FourSquareDecrypt(ciphertext, first, second))
+	assert.Equal(t, ciphertext, FOUR_SQUARE.Encrypt(plaintext, [2]Key{first, second}, 0, STANDARD_RULES))
+
+	// Plain squares follow the symbols of the keys
+	layout, err := ParseLayout("6x6", "", 'J')
+	assert.NoError(t, err)
+	first, second = layout.KeywordKey("four 4"), layout.KeywordKey("square 2")
+	plaintext = []byte("MEET4T10PM")
+	assert.Equal(t, plaintext, FourSquareDecrypt(FourSquareEncrypt(plaintext, first, second), first, second))
+	assert.Equal(t, "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ", first.plain().String())
+}
@@ -0,0 +1,320 @@
+package cipher
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Key is a key grid in row major order, 5x5 unless built from a Layout,
+// along with the letter a 5x5 grid of letters leaves out, and the row and
+// column of every symbol so encrypting does not have to look for them. The
+// rotations of a grid, moving rows and columns around cyclically, all
+// encrypt the same way and so are treated as one key.
+type Key struct {
+	grid     [MAX_GRID_CELLS]byte
+	rows     int
+	cols     int
+	excluded byte
+	row      [128]int8
+	col      [128]int8
+}
+
+// NewKey indexes grid, which must hold every letter in A-Z other than
+// excludedLetter once.
+func NewKey(grid [25]byte, excludedLetter byte) Key {
+	return newKey(grid[:], 5, 5, excludedLetter)
+}
+
+func newKey(cells []byte, rows int, cols int, excludedLetter byte) Key {
+	key := Key{rows: rows, cols: cols, excluded: excludedLetter}
+	copy(key.grid[:], cells)
+	for i := range key.row {
+		key.row[i], key.col[i] = -1, -1
+	}
+	for cell, l := range cells {
+		key.row[l], key.col[l] = int8(cell/cols), int8(cell%cols)
+	}
+	return key
+}
+
+// ParseKey reads a 5x5 key leaving out excludedLetter, see Layout.ParseKey.
+func ParseKey(text string, excludedLetter byte) (Key, error) {
+	return StandardLayout(excludedLetter).ParseKey(text)
+}
+
+// RandomKey returns a random 5x5 key, keeping any pinned letters in their
+// cells.
+func RandomKey(rng *rand.Rand, excludedLetter byte, pins *PinnedCells) Key {
+	if pins != nil {
+		return NewKey(GeneratePinnedKey(rng, excludedLetter, pins), excludedLetter)
+	}
+	return NewKey(GenerateRandomKey(rng, excludedLetter), excludedLetter)
+}
+
+// Rows returns the number of rows of the grid.
+func (key Key) Rows() int {
+	return key.rows
+}
+
+// Cols returns the number of columns of the grid.
+func (key Key) Cols() int {
+	return key.cols
+}
+
+// Size returns the number of cells of the grid.
+func (key Key) Size() int {
+	return key.rows * key.cols
+}
+
+// Cells returns a copy of the grid in row major order.
+func (key Key) Cells() []byte {
+	cells := make([]byte, key.Size())
+	copy(cells, key.grid[:])
+	return cells
+}
+
+// At returns the symbol in cell.
+func (key Key) At(cell int) byte {
+	return key.grid[cell]
+}
+
+// Excluded returns the letter left out of a 5x5 grid of letters, 0 for any
+// other grid.
+func (key Key) Excluded() byte {
+	return key.excluded
+}
+
+// Position returns the cell holding l, -1 when l is not in the grid.
+func (key Key) Position(l byte) int {
+	if l >= 128 || key.row[l] < 0 {
+		return -1
+	}
+	return int(key.row[l])*key.cols + int(key.col[l])
+}
+
+// String returns the symbols of the grid, row by row.
+func (key Key) String() string {
+	return string(key.grid[:key.Size()])
+}
+
+// Grid renders the key as one line of space separated symbols per row.
+func (key Key) Grid() string {
+	var grid strings.Builder
+	for cell := 0; cell < key.Size(); cell++ {
+		grid.WriteByte(key.grid[cell])
+		if cell%key.cols == key.cols-1 {
+			grid.WriteByte('\n')
+		} else {
+			grid.WriteByte(' ')
+		}
+	}
+	return grid.String()
+}
+
+// keyJSON is a 5x5 key as its grid and excluded letter, or any other key as
+// its grid and shape.
+type keyJSON struct {
+	Grid     string `json:"grid"`
+	Excluded string `json:"excluded,omitempty"`
+	Rows     int    `json:"rows,omitempty"`
+	Cols     int    `json:"cols,omitempty"`
+}
+
+func (key Key) MarshalJSON() ([]byte, error) {
+	if key.rows == 5 && key.cols == 5 && key.excluded != 0 {
+		return json.Marshal(keyJSON{Grid: key.String(), Excluded: string(key.excluded)})
+	}
+	return json.Marshal(keyJSON{Grid: key.String(), Rows: key.rows, Cols: key.cols})
+}
+
+func (key *Key) UnmarshalJSON(data []byte) error {
+	var text keyJSON
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+
+	if text.Rows != 0 || text.Cols != 0 {
+		layout := Layout{Rows: text.Rows, Cols: text.Cols, Alphabet: text.Grid}
+		if err := layout.Validate(); err != nil {
+			return err
+		}
+		*key = layout.NewKey([]byte(text.Grid))
+		return nil
+	}
+
+	if len(text.Excluded) != 1 || text.Excluded[0] < 'A' || text.Excluded[0] > 'Z' {
+		return fmt.Errorf("The excluded letter must be a single letter in A-Z, got %q", text.Excluded)
+	}
+	excluded := text.Excluded[0]
+
+	if len(text.Grid) != 25 {
+		return fmt.Errorf("Key grids must have 25 letters, got %d", len(text.Grid))
+	}
+	var grid [25]byte
+	for cell := range grid {
+		l := text.Grid[cell]
+		if l < 'A' || l > 'Z' || l == excluded {
+			return fmt.Errorf("Key grids must only hold letters in A-Z other than %c, got %q", excluded, l)
+		}
+		if strings.IndexByte(text.Grid[:cell], l) >= 0 {
+			return fmt.Errorf("The letter %c appears more than once in the key grid", l)
+		}
+		grid[cell] = l
+	}
+
+	*key = NewKey(grid, excluded)
+	return nil
+}
+
+// plain returns the grid of the symbols of key in ASCII order, the alphabet
+// less the excluded letter for a 5x5 grid of letters.
+func (key Key) plain() Key {
+	var present [128]bool
+	for cell := 0; cell < key.Size(); cell++ {
+		present[key.grid[cell]] = true
+	}
+
+	var cells [MAX_GRID_CELLS]byte
+	size := 0
+	for l := range present {
+		if present[l] {
+			cells[size] = byte(l)
+			size++
+		}
+	}
+	return newKey(cells[:size], key.rows, key.cols, key.excluded)
+}
+
+// Permute returns a slightly changed key for annealing, see PermutePinnedKey.
+// Pins only apply to 5x5 keys.
+func (key Key) Permute(rng *rand.Rand, pins *PinnedCells) Key {
+	cells := key.grid
+	permuteCells(rng, cells[:key.Size()], key.rows, key.cols, pins)
+	return newKey(cells[:key.Size()], key.rows, key.cols, key.excluded)
+}
+
+// Rotate moves every row of key up by rows and every column left by cols,
+// wrapping around.
+func (key Key) Rotate(rows int, cols int) Key {
+	var rotated [MAX_GRID_CELLS]byte
+	for cell := 0; cell < key.Size(); cell++ {
+		row := (cell/key.cols - rows%key.rows + key.rows) % key.rows
+		col := (cell%key.cols - cols%key.cols + key.cols) % key.cols
+		rotated[row*key.cols+col] = key.grid[cell]
+	}
+	return newKey(rotated[:key.Size()], key.rows, key.cols, key.excluded)
+}
+
+// Decrypt decrypts ciphertext, which must only hold symbols in the grid and
+// be of even length.
+func (key Key) Decrypt(ciphertext []byte) []byte {
+	return key.DecryptWith(ciphertext, STANDARD_RULES)
+}
+
+// Encrypt encrypts plaintext, which must already be prepared with
+// ValidateAndTransformPlaintext.
+func (key Key) Encrypt(plaintext []byte) []byte {
+	return key.EncryptWith(plaintext, STANDARD_RULES)
+}
+
+// DecryptWith decrypts ciphertext encrypted under rules, see Decrypt.
+func (key Key) DecryptWith(ciphertext []byte, rules Rules) []byte {
+	return key.playfair(ciphertext, -rules.shift(), rules)
+}
+
+// EncryptWith encrypts plaintext under rules, see Encrypt.
+func (key Key) EncryptWith(plaintext []byte, rules Rules) []byte {
+	return key.playfair(plaintext, rules.shift(), rules)
+}
+
+// playfair maps every digraph of text under rules, moving letters in one row
+// or column by shift cells, 1 being right or down.
+func (key Key) playfair(text []byte, shift int, rules Rules) []byte {
+	rows, cols := key.rows, key.cols
+	mapped := make([]byte, len(text))
+
+	for i := 1; i < len(text); i += 2 {
+		char1, char2 := text[i-1], text[i]
+		row1, col1 := int(key.row[char1]), int(key.col[char1])
+		row2, col2 := int(key.row[char2]), int(key.col[char2])
+
+		if row1 == row2 {
+			if rules == SAME_ROW_UNCHANGED_RULES {
+				mapped[i-1], mapped[i] = char1, char2
+				continue
+			}
+			// Same row: shift along it
+			mapped[i-1] = key.grid[row1*cols+wrap(col1+shift, cols)]
+			mapped[i] = key.grid[row2*cols+wrap(col2+shift, cols)]
+		} else if col1 == col2 {
+			// Same column: shift along it
+			mapped[i-1] = key.grid[wrap(row1+shift, rows)*cols+col1]
+			mapped[i] = key.grid[wrap(row2+shift, rows)*cols+col2]
+		} else if rules == OPPOSITE_CORNER_RULES {
+			// Rectangle: swap rows
+			mapped[i-1] = key.grid[row2*cols+col1]
+			mapped[i] = key.grid[row1*cols+col2]
+		} else {
+			// Rectangle: swap columns
+			mapped[i-1] = key.grid[row1*cols+col2]
+			mapped[i] = key.grid[row2*cols+col1]
+		}
+	}
+
+	return mapped
+}
+
+// wrap brings i, at most one lap out, back into [0, n).
+func wrap(i int, n int) int {
+	if i < 0 {
+		return i + n
+	} else if i >= n {
+		return i - n
+	}
+	return i
+}
+
+// Canonical returns the rotation of key with its first symbol in ASCII order
+// in the top left cell, the same for every key in the equivalence class.
+func (key Key) Canonical() Key {
+	first := 0
+	for cell := 0; cell < key.Size(); cell++ {
+		if key.grid[cell] < key.grid[first] {
+			first = cell
+		}
+	}
+	return key.Rotate(first/key.cols, first%key.cols)
+}
+
+// Equivalent reports whether key and other encrypt the same way.
+func (key Key) Equivalent(other Key) bool {
+	return key.Canonical() == other.Canonical()
+}
+
+// Distance returns the fewest swaps of two cells needed to turn key into any
+// rotation of other, zero for equivalent keys. Keys must hold the same
+// symbols in the same shape.
+func (key Key) Distance(other Key) int {
+	best := key.Size()
+	for rows := 0; rows < key.rows; rows++ {
+		for cols := 0; cols < key.cols; cols++ {
+			rotated := other.Rotate(rows, cols)
+
+			// A permutation with c cycles takes size - c swaps to undo
+			var visited [MAX_GRID_CELLS]bool
+			swaps := 0
+			for start := 0; start < key.Size(); start++ {
+				for cell := start; !visited[cell]; cell = rotated.Position(key.grid[cell]) {
+					if cell != start {
+						swaps++
+					}
+					visited[cell] = true
+				}
+			}
+			best = min(best, swaps)
+		}
+	}
+	return best
+}
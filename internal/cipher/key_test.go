@@ -0,0 +1,83 @@
+package cipher
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyEquivalence(t *testing.T) {
+	grid := KeywordKey("playfair example", 'J')
+	key := NewKey(grid, 'J')
+	rotated := NewKey(RotateKey(grid, 3, 1), 'J')
+
+	alphabet := NewKey(KeywordKey("", 'J'), 'J')
+	assert.Equal(t, alphabet.Canonical(), alphabet)
+	assert.Equal(t, byte('A'), rotated.Canonical().Cells()[0])
+	assert.Equal(t, key.Canonical(), rotated.Canonical())
+	assert.True(t, key.Equivalent(rotated))
+	assert.Equal(t, 0, key.Distance(rotated))
+
+	cells := rotated.Cells()
+	cells[3], cells[17] = cells[17], cells[3]
+	swapped := NewKey([25]byte(cells), 'J')
+	assert.False(t, key.Equivalent(swapped))
+	assert.Equal(t, 1, key.Distance(swapped))
+	assert.Equal(t, 1, swapped.Distance(key))
+
+	cells[0], cells[1], cells[2] = cells[1], cells[2], cells[0]
+	assert.Equal(t, 3, key.Distance(NewKey([25]byte(cells), 'J')))
+}
+
+func TestParseKey(t *testing.T) {
+	want := "PLAYFIREXMBCDGHKNOQSTUVWZ"
+
+	for _, text := range []string{
+		"playfair example",
+		"PLAYF IREXM BCDGH KNOQS TUVWZ",
+		`{"grid": "PLAYFIREXMBCDGHKNOQSTUVWZ", "excluded": "J"}`,
+	} {
+		key, err := ParseKey(text, 'J')
+		assert.NoError(t, err)
+		assert.Equal(t, want, key.String())
+		assert.Equal(t, byte('J'), key.Excluded())
+		assert.Equal(t, 5, key.Position('I'))
+		assert.Equal(t, -1, key.Position('J'))
+	}
+
+	for _, text := range []string{
+		"play-fair",
+		`{"grid": "PLAYFIREXMBCDGHKNOQSTUVWZ", "excluded": "Q"}`,
+		`{"grid": "PLAYFIREXMBCDGHKNOQSTUVWP", "excluded": "J"}`,
+		`{"grid": "PLAYF", "excluded": "J"}`,
+	} {
+		_, err := ParseKey(text, 'J')
+		assert.Error(t, err, text)
+	}
+}
+
+func TestKeyRendering(t *testing.T) {
+	key, err := ParseKey("playfair example", 'J')
+	assert.NoError(t, err)
+	assert.Equal(t, "P L A Y F\nI R E X M\nB C D G H\nK N O Q S\nT U V W Z\n", key.Grid())
+
+	data, err := json.Marshal(key)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"grid": "PLAYFIREXMBCDGHKNOQSTUVWZ", "excluded": "J"}`, string(data))
+
+	var decoded Key
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, key, decoded)
+}
+
+func TestKeyEncryptDecrypt(t *testing.T) {
+	key, err := ParseKey("playfair example", 'J')
+	assert.NoError(t, err)
+
+	plaintext := []byte("HIDETHEGOLDINTHETREXESTUMP")
+	ciphertext := key.Encrypt(plaintext)
+	assert.Equal(t, "BMODZBXDNABEKUDMUIXMMOUVIF", string(ciphertext))
+	assert.Equal(t, plaintext, key.Decrypt(ciphertext))
+	assert.Equal(t, ciphertext, PlayfairEncrypt(plaintext, [25]byte(key.Cells()), 'J'))
+}
@@ -0,0 +1,190 @@
+package cipher
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Largest grid a key can hold, 8x8
+	MAX_GRID_CELLS int = 64
+	// Every letter and digit, for 6x6 grids
+	ALPHANUMERIC string = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// Layout is the shape of a key grid and the symbols filling it, one per
+// cell. Symbols are printable ASCII other than space and lower case letters.
+type Layout struct {
+	Rows     int
+	Cols     int
+	Alphabet string
+}
+
+// StandardLayout is the usual 5x5 grid of every letter but excludedLetter.
+func StandardLayout(excludedLetter byte) Layout {
+	var alphabet []byte
+	for l := byte('A'); l <= 'Z'; l++ {
+		if l != excludedLetter {
+			alphabet = append(alphabet, l)
+		}
+	}
+	return Layout{Rows: 5, Cols: 5, Alphabet: string(alphabet)}
+}
+
+// ParseLayout reads a grid shape such as 5x5 or 6x6. With no alphabet 5x5
+// grids leave out excludedLetter and 6x6 grids hold ALPHANUMERIC, any other
+// shape needs an alphabet with a symbol for every cell.
+func ParseLayout(shape string, alphabet string, excludedLetter byte) (Layout, error) {
+	rowsText, colsText, found := strings.Cut(strings.ToLower(shape), "x")
+	rows, rowsErr := strconv.Atoi(rowsText)
+	cols, colsErr := strconv.Atoi(colsText)
+	if !found || rowsErr != nil || colsErr != nil {
+		return Layout{}, fmt.Errorf("Grids must look like ROWSxCOLS, got %s", shape)
+	}
+
+	layout := Layout{Rows: rows, Cols: cols, Alphabet: strings.ToUpper(alphabet)}
+	if alphabet == "" {
+		switch {
+		case rows == 5 && cols == 5:
+			layout = StandardLayout(excludedLetter)
+		case rows == 6 && cols == 6:
+			layout.Alphabet = ALPHANUMERIC
+		default:
+			return Layout{}, fmt.Errorf("A %dx%d grid needs an alphabet of %d symbols", rows, cols, rows*cols)
+		}
+	}
+
+	if err := layout.Validate(); err != nil {
+		return Layout{}, err
+	}
+	return layout, nil
+}
+
+// Validate reports whether the layout can be used for a key.
+func (layout Layout) Validate() error {
+	if layout.Rows < 2 || layout.Cols < 2 || layout.Size() > MAX_GRID_CELLS {
+		return fmt.Errorf("Grids must be at least 2x2 and hold at most %d cells, got %dx%d", MAX_GRID_CELLS, layout.Rows, layout.Cols)
+	}
+	if len(layout.Alphabet) != layout.Size() {
+		return fmt.Errorf("A %dx%d grid needs an alphabet of %d symbols, got %d", layout.Rows, layout.Cols, layout.Size(), len(layout.Alphabet))
+	}
+	for i := 0; i < len(layout.Alphabet); i++ {
+		l := layout.Alphabet[i]
+		if l <= ' ' || l > '~' || ('a' <= l && l <= 'z') {
+			return fmt.Errorf("Alphabets must only hold printable symbols other than lower case letters, got %q", l)
+		}
+		if strings.IndexByte(layout.Alphabet[:i], l) >= 0 {
+			return fmt.Errorf("The symbol %c appears more than once in the alphabet", l)
+		}
+	}
+	return nil
+}
+
+// Size is the number of cells in the grid.
+func (layout Layout) Size() int {
+	return layout.Rows * layout.Cols
+}
+
+// IsStandard reports whether the layout is a 5x5 grid of all letters but one.
+func (layout Layout) IsStandard() bool {
+	return layout.Rows == 5 && layout.Cols == 5 && layout.Excluded() != 0
+}
+
+// Excluded returns the letter a 5x5 grid of letters leaves out, 0 for any
+// other layout.
+func (layout Layout) Excluded() byte {
+	if layout.Size() != 25 {
+		return 0
+	}
+	for i := 0; i < len(layout.Alphabet); i++ {
+		if layout.Alphabet[i] < 'A' || layout.Alphabet[i] > 'Z' {
+			return 0
+		}
+	}
+	for l := byte('A'); l <= 'Z'; l++ {
+		if !layout.Contains(l) {
+			return l
+		}
+	}
+	return 0
+}
+
+// Contains reports whether l is one of the symbols of the grid.
+func (layout Layout) Contains(l byte) bool {
+	return strings.IndexByte(layout.Alphabet, l) >= 0
+}
+
+// NewKey indexes cells laid out in the grid, which must hold every symbol of
+// the alphabet once.
+func (layout Layout) NewKey(cells []byte) Key {
+	return newKey(cells, layout.Rows, layout.Cols, layout.Excluded())
+}
+
+// KeywordKey builds the grid for keyword the usual way, its distinct symbols
+// in order followed by the rest of the alphabet. Symbols outside the
+// alphabet are skipped, lower case letters are upper cased.
+func (layout Layout) KeywordKey(keyword string) Key {
+	var cells []byte
+	var used [128]bool
+
+	add := func(l byte) {
+		if 'a' <= l && l <= 'z' {
+			l -= 'a' - 'A'
+		}
+		if l >= 128 || used[l] || !layout.Contains(l) {
+			return
+		}
+		used[l] = true
+		cells = append(cells, l)
+	}
+
+	for i := 0; i < len(keyword); i++ {
+		add(keyword[i])
+	}
+	for i := 0; i < len(layout.Alphabet); i++ {
+		add(layout.Alphabet[i])
+	}
+
+	return layout.NewKey(cells)
+}
+
+// RandomKey returns a shuffled grid of the alphabet.
+func (layout Layout) RandomKey(rng *rand.Rand) Key {
+	cells := []byte(layout.Alphabet)
+	rng.Shuffle(len(cells), func(i, j int) { cells[i], cells[j] = cells[j], cells[i] })
+	return layout.NewKey(cells)
+}
+
+// ParseKey reads a key either as JSON, as written by Key.MarshalJSON, or as
+// a keyword laid out by KeywordKey. A full grid is a keyword that lays out to
+// itself, with any whitespace between its symbols ignored. The key must fit
+// the layout.
+func (layout Layout) ParseKey(text string) (Key, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "{") {
+		var key Key
+		if err := key.UnmarshalJSON([]byte(text)); err != nil {
+			return Key{}, err
+		}
+		if key.rows != layout.Rows || key.cols != layout.Cols {
+			return Key{}, fmt.Errorf("The key is a %dx%d grid, not %dx%d", key.rows, key.cols, layout.Rows, layout.Cols)
+		}
+		for _, l := range key.Cells() {
+			if !layout.Contains(l) {
+				return Key{}, fmt.Errorf("The key holds %c, which is not in the alphabet", l)
+			}
+		}
+		return key, nil
+	}
+
+	upper := strings.ToUpper(text)
+	for i := 0; i < len(upper); i++ {
+		l := upper[i]
+		if !layout.Contains(l) && (l < 'A' || l > 'Z') && !strings.ContainsRune(" \t\n", rune(l)) {
+			return Key{}, fmt.Errorf("Keys must only contain letters or symbols of the alphabet, %c", l)
+		}
+	}
+	return layout.KeywordKey(text), nil
+}
@@ -0,0 +1,87 @@
+package cipher
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLayout(t *testing.T) {
+	layout, err := ParseLayout("5x5", "", 'J')
+	assert.NoError(t, err)
+	assert.Equal(t, StandardLayout('J'), layout)
+	assert.True(t, layout.IsStandard())
+	assert.Equal(t, byte('J'), layout.Excluded())
+
+	layout, err = ParseLayout("6x6", "", 'J')
+	assert.NoError(t, err)
+	assert.Equal(t, ALPHANUMERIC, layout.Alphabet)
+	assert.False(t, layout.IsStandard())
+	assert.Equal(t, byte(0), layout.Excluded())
+
+	layout, err = ParseLayout("3X4", "abcdef012345", 'J')
+	assert.NoError(t, err)
+	assert.Equal(t, Layout{Rows: 3, Cols: 4, Alphabet: "ABCDEF012345"}, layout)
+
+	for _, spec := range [][2]string{
+		{"6", ""},
+		{"4x4", ""},
+		{"3x4", "ABCDEF01234"},
+		{"3x4", "ABCDEF012344"},
+		{"9x9", ""},
+		{"1x4", "ABCD"},
+	} {
+		_, err := ParseLayout(spec[0], spec[1], 'J')
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestAlphanumericKey(t *testing.T) {
+	layout, err := ParseLayout("6x6", "", 'J')
+	assert.NoError(t, err)
+
+	key, err := layout.ParseKey("playfair 1234")
+	assert.NoError(t, err)
+	assert.Equal(t, "PLAYFIR1234BCDEGHJKMNOQSTUVWXZ056789", key.String())
+	assert.Equal(t, "P L A Y F I\nR 1 2 3 4 B\nC D E G H J\nK M N O Q S\nT U V W X Z\n0 5 6 7 8 9\n", key.Grid())
+
+	// Same row, same column, rectangle and wrapping around both ways
+	plaintext := []byte("PL9IRC0P1G")
+	ciphertext := key.Encrypt(plaintext)
+	assert.Equal(t, "LAIBCKPR3D", string(ciphertext))
+	assert.Equal(t, plaintext, key.Decrypt(ciphertext))
+
+	rotated := key.Rotate(2, 5)
+	assert.Equal(t, ciphertext, rotated.Encrypt(plaintext))
+	assert.True(t, key.Equivalent(rotated))
+	assert.Equal(t, byte('0'), rotated.Canonical().At(0))
+
+	data, err := json.Marshal(key)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"grid": "PLAYFIR1234BCDEGHJKMNOQSTUVWXZ056789", "rows": 6, "cols": 6}`, string(data))
+	decoded, err := layout.ParseKey(string(data))
+	assert.NoError(t, err)
+	assert.Equal(t, key, decoded)
+
+	_, err = StandardLayout('J').ParseKey(string(data))
+	assert.Error(t, err)
+}
+
+func TestPermuteLayoutKey(t *testing.T) {
+	layout := Layout{Rows: 3, Cols: 4, Alphabet: "ABCDEF012345"}
+	rng := rand.New(rand.NewSource(42))
+	key := layout.RandomKey(rng)
+
+	for i := 0; i < 1000; i++ {
+		key = key.Permute(rng, nil)
+
+		assert.Equal(t, 3, key.Rows())
+		assert.Equal(t, 4, key.Cols())
+		assert.ElementsMatch(t, []byte(layout.Alphabet), key.Cells())
+		for cell, l := range key.Cells() {
+			assert.Equal(t, cell, key.Position(l))
+		}
+	}
+}
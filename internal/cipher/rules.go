@@ -0,0 +1,55 @@
+package cipher
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rules are the rules a Playfair grid maps digraphs by, which published
+// variants of the cipher differ on. Every profile keeps the rotations of a
+// grid equivalent.
+type Rules int
+
+const (
+	// STANDARD_RULES shift letters in one row right and letters in one
+	// column down, and take the corner in the same row for rectangles.
+	STANDARD_RULES Rules = iota
+	// LEFT_UP_RULES shift letters in one row left and letters in one column
+	// up, the way the standard rules decrypt.
+	LEFT_UP_RULES
+	// OPPOSITE_CORNER_RULES take the corner in the other row for
+	// rectangles, giving the standard digraph reversed.
+	OPPOSITE_CORNER_RULES
+	// SAME_ROW_UNCHANGED_RULES leave letters in one row as they are.
+	SAME_ROW_UNCHANGED_RULES
+)
+
+var rulesNames = []string{
+	STANDARD_RULES:           "standard",
+	LEFT_UP_RULES:            "left-up",
+	OPPOSITE_CORNER_RULES:    "opposite-corner",
+	SAME_ROW_UNCHANGED_RULES: "same-row-unchanged",
+}
+
+// ParseRules reads the name of a rule profile, as returned by Rules.String.
+func ParseRules(name string) (Rules, error) {
+	for rules, rulesName := range rulesNames {
+		if strings.EqualFold(name, rulesName) {
+			return Rules(rules), nil
+		}
+	}
+	return STANDARD_RULES, fmt.Errorf("Rules must be one of %s, got %s", strings.Join(rulesNames, ", "), name)
+}
+
+func (rules Rules) String() string {
+	return rulesNames[rules]
+}
+
+// shift returns the cells letters in one row or column move by when
+// encrypting, 1 being right or down.
+func (rules Rules) shift() int {
+	if rules == LEFT_UP_RULES {
+		return -1
+	}
+	return 1
+}
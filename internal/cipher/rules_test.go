@@ -0,0 +1,45 @@
+package cipher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRules(t *testing.T) {
+	key, err := ParseKey("playfair example", 'J')
+	assert.NoError(t, err)
+
+	plaintext := []byte("HIDETHEGOLDINTHETREXESTUMP")
+	tests := []struct {
+		rules      Rules
+		ciphertext string
+	}{
+		{rules: STANDARD_RULES, ciphertext: "BMODZBXDNABEKUDMUIXMMOUVIF"},
+		{rules: LEFT_UP_RULES, ciphertext: "BMEAZBXDNABEKUDMUIREMOZTIF"},
+		{rules: OPPOSITE_CORNER_RULES, ciphertext: "MBODBZDXANEBUKMDIUXMOMUVFI"},
+		{rules: SAME_ROW_UNCHANGED_RULES, ciphertext: "BMODZBXDNABEKUDMUIEXMOTUIF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rules.String(), func(t *testing.T) {
+			ciphertext := key.EncryptWith(plaintext, tt.rules)
+			assert.Equal(t, tt.ciphertext, string(ciphertext))
+			assert.Equal(t, plaintext, key.DecryptWith(ciphertext, tt.rules))
+
+			// Rotations of the grid stay equivalent
+			assert.Equal(t, ciphertext, key.Rotate(2, 3).EncryptWith(plaintext, tt.rules))
+
+			keys := [2]Key{key}
+			assert.Equal(t, ciphertext, PLAYFAIR.Encrypt(plaintext, keys, 0, tt.rules))
+			assert.Equal(t, plaintext, PLAYFAIR.Decrypt(PLAYFAIR.Encrypt(plaintext, keys, 3, tt.rules), keys, 3, tt.rules))
+
+			parsed, err := ParseRules(tt.rules.String())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.rules, parsed)
+		})
+	}
+
+	_, err = ParseRules("diagonal")
+	assert.Error(t, err)
+}
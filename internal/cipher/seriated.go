@@ -0,0 +1,99 @@
+package cipher
+
+import "slices"
+
+// SeriatedPlayfairEncrypt encrypts plaintext with Seriated Playfair under key:
+// the plaintext is written in groups of two rows of period symbols and every
+// column is encrypted as a digraph, the ciphertext being read back row by row.
+// A last group shorter than two rows is split into two rows of half its
+// length. Plaintext must be of even length and no column may hold the same
+// symbol twice.
+func SeriatedPlayfairEncrypt(plaintext []byte, key Key, period int) []byte {
+	return unseriate(key.Encrypt(seriate(plaintext, period)), period)
+}
+
+// SeriatedPlayfairDecrypt decrypts ciphertext encrypted by
+// SeriatedPlayfairEncrypt with the same key and period.
+func SeriatedPlayfairDecrypt(ciphertext []byte, key Key, period int) []byte {
+	return unseriate(key.Decrypt(seriate(ciphertext, period)), period)
+}
+
+// SeparateColumns prepares letters for Seriated Playfair with period the way
+// Playfair splits letter pairs: sep goes in front of any symbol that would
+// sit below the same symbol, and text is padded with sep to an even length.
+// Columns of sep over sep are left as they are.
+func SeparateColumns(text []byte, sep byte, period int) []byte {
+	separated := slices.Clone(text)
+	padded := len(separated)%2 != 0
+	if padded {
+		separated = append(separated, sep)
+	}
+
+	// Separating a column shifts the rest of the text, and can change how the
+	// last group is split, so look again from the same group
+	for start := 0; start+1 < len(separated); {
+		n := min(period, (len(separated)-start)/2)
+		doubled := -1
+		for i := 0; i < n; i++ {
+			if separated[start+i] == separated[start+n+i] && separated[start+i] != sep {
+				doubled = start + n + i
+				break
+			}
+		}
+		if doubled < 0 {
+			start += 2 * n
+			continue
+		}
+
+		separated = slices.Insert(separated, doubled, sep)
+		if padded {
+			separated = separated[:len(separated)-1]
+		} else {
+			separated = append(separated, sep)
+		}
+		padded = !padded
+	}
+
+	return separated
+}
+
+// seriate reorders text, written in groups of two rows of period symbols, so
+// that the two symbols of every column come next to each other.
+func seriate(text []byte, period int) []byte {
+	seriated := make([]byte, len(text))
+	for start := 0; start+1 < len(text); start += 2 * period {
+		n := min(period, (len(text)-start)/2)
+		for i := 0; i < n; i++ {
+			seriated[start+2*i] = text[start+i]
+			seriated[start+2*i+1] = text[start+n+i]
+		}
+	}
+	return seriated
+}
+
+// unseriate undoes seriate, writing the digraphs of text back into rows.
+func unseriate(text []byte, period int) []byte {
+	rows := make([]byte, len(text))
+	for start := 0; start+1 < len(text); start += 2 * period {
+		n := min(period, (len(text)-start)/2)
+		for i := 0; i < n; i++ {
+			rows[start+i] = text[start+2*i]
+			rows[start+n+i] = text[start+2*i+1]
+		}
+	}
+	return rows
+}
+
+// HasDoubledColumns reports whether text, written in rows of period symbols as
+// for Seriated Playfair, has a column holding the same symbol twice. Playfair
+// never encrypts to such a column, so ciphertexts with one were not seriated
+// with period.
+func HasDoubledColumns(text []byte, period int) bool {
+	seriated := seriate(text, period)
+	for i := 1; i < len(seriated); i += 2 {
+		if seriated[i-1] == seriated[i] {
+			return true
+		}
+	}
+	return false
+}
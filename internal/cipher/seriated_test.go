@@ -0,0 +1,41 @@
+package cipher
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeriate(t *testing.T) {
+	// The last group is split in half
+	text := []byte("ABCDEFGHIJ")
+	assert.Equal(t, "ADBECFGIHJ", string(seriate(text, 3)))
+	assert.Equal(t, text, unseriate(seriate(text, 3), 3))
+	assert.Equal(t, "AFBGCHDIEJ", string(seriate(text, 7)))
+	assert.Equal(t, text, seriate(text, 1))
+}
+
+func TestSeparateColumns(t *testing.T) {
+	// Separating the first column shifts the rest of the group along
+	assert.Equal(t, "ABCXABCX", string(SeparateColumns([]byte("ABCABC"), 'X', 3)))
+	separated := SeparateColumns([]byte("BOOKKEEPERSTOOLLOOPS"), 'X', 4)
+	assert.False(t, HasDoubledColumns(separated, 4))
+	assert.Equal(t, "BOOKKEEPERSTOOLLOOPS", strings.ReplaceAll(string(separated), "X", ""))
+}
+
+func TestSeriatedPlayfair(t *testing.T) {
+	key, err := ParseKey("playfair example", 'J')
+	assert.NoError(t, err)
+
+	plaintext := []byte("HIDETHEGOLDINTHETREXESTUMP")
+	ciphertext := SeriatedPlayfairEncrypt(plaintext, key, 4)
+	assert.Equal(t, string(unseriate(key.Encrypt(seriate(plaintext, 4)), 4)), string(ciphertext))
+	assert.Equal(t, plaintext, SeriatedPlayfairDecrypt(ciphertext, key, 4))
+	assert.Equal(t, ciphertext, PLAYFAIR.Encrypt(plaintext, [2]Key{key}, 4, STANDARD_RULES))
+
+	// Only the right period leaves no column doubled
+	assert.False(t, HasDoubledColumns(ciphertext, 4))
+	assert.True(t, HasDoubledColumns([]byte("ABCAEF"), 3))
+	assert.False(t, HasDoubledColumns([]byte("ABCAEF"), 2))
+}
@@ -0,0 +1,42 @@
+package cipher
+
+// TwoSquareEncrypt encrypts plaintext with the Two-Square cipher, the first
+// symbol of every digraph found in first and the second in second. Horizontal
+// squares sit side by side with first on the left, a digraph in one row comes
+// out reversed. Vertical squares sit one above the other with first on top, a
+// digraph in one column comes out unchanged. Both keys must share a layout.
+func TwoSquareEncrypt(plaintext []byte, first Key, second Key, vertical bool) []byte {
+	if vertical {
+		return twoSquare(plaintext, first, second, first, second)
+	}
+	return twoSquare(plaintext, first, second, second, first)
+}
+
+// TwoSquareDecrypt decrypts ciphertext encrypted by TwoSquareEncrypt with the
+// same keys, which must be of even length.
+func TwoSquareDecrypt(ciphertext []byte, first Key, second Key, vertical bool) []byte {
+	if vertical {
+		return twoSquare(ciphertext, first, second, first, second)
+	}
+	return twoSquare(ciphertext, second, first, first, second)
+}
+
+// twoSquare maps every digraph, its first symbol looked up in in1 and its
+// second in in2, to the other corners of their rectangle: the row of the
+// first and column of the second in out1, then the row of the second and
+// column of the first in out2.
+func twoSquare(text []byte, in1 Key, in2 Key, out1 Key, out2 Key) []byte {
+	cols := in1.cols
+	mapped := make([]byte, len(text))
+
+	for i := 1; i < len(text); i += 2 {
+		char1, char2 := text[i-1], text[i]
+		row1, col1 := int(in1.row[char1]), int(in1.col[char1])
+		row2, col2 := int(in2.row[char2]), int(in2.col[char2])
+
+		mapped[i-1] = out1.grid[row1*cols+col2]
+		mapped[i] = out2.grid[row2*cols+col1]
+	}
+
+	return mapped
+}
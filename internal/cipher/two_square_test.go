@@ -0,0 +1,53 @@
+package cipher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTwoSquare(t *testing.T) {
+	first, err := ParseKey("example", 'Q')
+	assert.NoError(t, err)
+	second, err := ParseKey("keyword", 'Q')
+	assert.NoError(t, err)
+
+	plaintext := []byte("HELPMEOBIWANKENOBI")
+	tests := []struct {
+		cipherType Type
+		ciphertext string
+	}{
+		{cipherType: TWO_SQUARE, ciphertext: "GXBNEMPBIAYRGPSEBH"},
+		{cipherType: TWO_SQUARE_VERTICAL, ciphertext: "HEDLXWSDJYANHOTKDG"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cipherType.String(), func(t *testing.T) {
+			keys := [2]Key{first, second}
+			ciphertext := tt.cipherType.Encrypt(plaintext, keys, 0, STANDARD_RULES)
+			assert.Equal(t, tt.ciphertext, string(ciphertext))
+			assert.Equal(t, plaintext, tt.cipherType.Decrypt(ciphertext, keys, 0, STANDARD_RULES))
+		})
+	}
+
+	// Horizontal digraphs in one row come out reversed, vertical ones in one
+	// column unchanged
+	assert.Equal(t, "KE", string(TwoSquareEncrypt([]byte("EK"), first, second, false)))
+	assert.Equal(t, "EK", string(TwoSquareEncrypt([]byte("EK"), first, second, true)))
+}
+
+func TestParseType(t *testing.T) {
+	for _, cipherType := range []Type{PLAYFAIR, TWO_SQUARE, TWO_SQUARE_VERTICAL, FOUR_SQUARE, DOUBLE_PLAYFAIR} {
+		parsed, err := ParseType(cipherType.String())
+		assert.NoError(t, err)
+		assert.Equal(t, cipherType, parsed)
+	}
+
+	parsed, err := ParseType("TwoSquare")
+	assert.NoError(t, err)
+	assert.Equal(t, TWO_SQUARE, parsed)
+	assert.Equal(t, 2, parsed.Grids())
+
+	_, err = ParseType("vigenere")
+	assert.Error(t, err)
+}
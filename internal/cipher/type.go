@@ -0,0 +1,98 @@
+package cipher
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is one of the digraphic ciphers keyed by grids. Every cipher takes its
+// grids as a pair, Playfair only using the first.
+type Type int
+
+const (
+	PLAYFAIR Type = iota
+	TWO_SQUARE
+	TWO_SQUARE_VERTICAL
+	FOUR_SQUARE
+	DOUBLE_PLAYFAIR
+)
+
+var typeNames = []string{
+	PLAYFAIR:            "playfair",
+	TWO_SQUARE:          "twosquare",
+	TWO_SQUARE_VERTICAL: "twosquare-vertical",
+	FOUR_SQUARE:         "foursquare",
+	DOUBLE_PLAYFAIR:     "doubleplayfair",
+}
+
+// ParseType reads the name of a cipher, as returned by Type.String.
+func ParseType(name string) (Type, error) {
+	for cipherType, typeName := range typeNames {
+		if strings.EqualFold(name, typeName) {
+			return Type(cipherType), nil
+		}
+	}
+	return PLAYFAIR, fmt.Errorf("Ciphers must be one of %s, got %s", strings.Join(typeNames, ", "), name)
+}
+
+func (cipherType Type) String() string {
+	return typeNames[cipherType]
+}
+
+// Grids returns the number of key grids the cipher takes.
+func (cipherType Type) Grids() int {
+	if cipherType == PLAYFAIR {
+		return 1
+	}
+	return 2
+}
+
+// AllowsDoubles reports whether a ciphertext digraph can hold the same symbol
+// twice, which only Playfair never encrypts to.
+func (cipherType Type) AllowsDoubles() bool {
+	return cipherType != PLAYFAIR
+}
+
+// Encrypt encrypts plaintext under keys, which must be prepared the same way
+// as for PlayfairEncrypt. A period above 1 seriates the digraphs as
+// SeriatedPlayfairEncrypt does, 0 forms them from neighbouring symbols.
+// Playfair grids map digraphs under rules, the other ciphers ignore them.
+func (cipherType Type) Encrypt(plaintext []byte, keys [2]Key, period int, rules Rules) []byte {
+	if period > 1 {
+		return unseriate(cipherType.Encrypt(seriate(plaintext, period), keys, 0, rules), period)
+	}
+
+	switch cipherType {
+	case TWO_SQUARE:
+		return TwoSquareEncrypt(plaintext, keys[0], keys[1], false)
+	case TWO_SQUARE_VERTICAL:
+		return TwoSquareEncrypt(plaintext, keys[0], keys[1], true)
+	case FOUR_SQUARE:
+		return FourSquareEncrypt(plaintext, keys[0], keys[1])
+	case DOUBLE_PLAYFAIR:
+		return DoublePlayfairEncrypt(plaintext, keys[0], keys[1])
+	default:
+		return keys[0].EncryptWith(plaintext, rules)
+	}
+}
+
+// Decrypt decrypts ciphertext under keys, period and rules, which must be of
+// even length.
+func (cipherType Type) Decrypt(ciphertext []byte, keys [2]Key, period int, rules Rules) []byte {
+	if period > 1 {
+		return unseriate(cipherType.Decrypt(seriate(ciphertext, period), keys, 0, rules), period)
+	}
+
+	switch cipherType {
+	case TWO_SQUARE:
+		return TwoSquareDecrypt(ciphertext, keys[0], keys[1], false)
+	case TWO_SQUARE_VERTICAL:
+		return TwoSquareDecrypt(ciphertext, keys[0], keys[1], true)
+	case FOUR_SQUARE:
+		return FourSquareDecrypt(ciphertext, keys[0], keys[1])
+	case DOUBLE_PLAYFAIR:
+		return DoublePlayfairDecrypt(ciphertext, keys[0], keys[1])
+	default:
+		return keys[0].DecryptWith(ciphertext, rules)
+	}
+}
@@ -0,0 +1,237 @@
+package cmdutil
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ENVELOPE_PREFIX starts every envelope, naming the version of its format.
+const ENVELOPE_PREFIX = "PFE1."
+
+// Envelope is a ciphertext along with the format mask of its plaintext, which
+// restores the spacing, punctuation, case, digits and excluded letters that
+// preparing the plaintext took out. The mask is not encrypted: it gives away
+// the shape of the plaintext, word lengths included, where every excluded
+// letter stands, and where every number stands and how many digits it has.
+type Envelope struct {
+	Ciphertext string
+	Mask       string
+}
+
+// String writes the envelope as PFE1.<ciphertext>.<mask>, the mask in
+// unpadded URL safe base64.
+func (envelope Envelope) String() string {
+	return ENVELOPE_PREFIX + envelope.Ciphertext + "." + base64.RawURLEncoding.EncodeToString([]byte(envelope.Mask))
+}
+
+// IsEnvelope reports whether text is written as an envelope rather than a
+// bare ciphertext.
+func IsEnvelope(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), ENVELOPE_PREFIX)
+}
+
+// ValidateAndTransformEnvelope reads an envelope written by Envelope.String,
+// the ciphertext still needing validating on its own.
+func ValidateAndTransformEnvelope(text string) (error, Envelope) {
+	body, ok := strings.CutPrefix(strings.TrimSpace(text), ENVELOPE_PREFIX)
+	dot := strings.LastIndexByte(body, '.')
+	if !ok || dot < 0 {
+		return fmt.Errorf("Envelopes must be written as %s<ciphertext>.<mask>", ENVELOPE_PREFIX), Envelope{}
+	}
+
+	mask, err := base64.RawURLEncoding.DecodeString(body[dot+1:])
+	if err != nil || !strings.ContainsRune(string(mask), '|') {
+		return fmt.Errorf("The format mask of the envelope is not valid"), Envelope{}
+	}
+
+	return nil, Envelope{Ciphertext: body[:dot], Mask: string(mask)}
+}
+
+// Restore rebuilds the original plaintext from plaintext, the decryption of
+// the envelope, prepared with reduction. Spelled out numbers go back to
+// digits when they spell one exactly, a wrong key giving them as letters.
+func (envelope Envelope) Restore(plaintext []byte, reduction Reduction) (error, string) {
+	nulls, template, _ := strings.Cut(envelope.Mask, "|")
+
+	// Take the separators and padding back out
+	letters := string(plaintext)
+	if nulls != "" {
+		fields := strings.Split(nulls, ",")
+		for i := len(fields) - 1; i >= 0; i-- {
+			null, err := strconv.Atoi(fields[i])
+			if err != nil || null < 0 || null >= len(letters) {
+				return fmt.Errorf("The format mask does not fit the plaintext"), ""
+			}
+			letters = letters[:null] + letters[null+1:]
+		}
+	}
+
+	var restored strings.Builder
+	next := func(n int) (string, bool) {
+		if n > len(letters) {
+			return "", false
+		}
+		taken := letters[:n]
+		letters = letters[n:]
+		return taken, true
+	}
+
+	for i := 0; i < len(template); {
+		mark := template[i]
+		i++
+
+		switch mark {
+		case maskLiteral:
+			l, size := utf8.DecodeRuneInString(template[i:])
+			restored.WriteRune(l)
+			i += size
+			continue
+		case maskOmittedLower:
+			restored.WriteRune(unicode.ToLower(reduction.Excluded))
+			continue
+		case maskOmittedUpper:
+			restored.WriteRune(reduction.Excluded)
+			continue
+		case maskNumber:
+			var digits, count int
+			end := strings.IndexByte(template[i:], ';')
+			if end < 0 {
+				return fmt.Errorf("The format mask is not valid"), ""
+			}
+			if _, err := fmt.Sscanf(template[i:i+end], "%d:%d", &digits, &count); err != nil {
+				return fmt.Errorf("The format mask is not valid"), ""
+			}
+			i += end + 1
+
+			words, ok := next(count)
+			if !ok {
+				return fmt.Errorf("The format mask does not fit the plaintext"), ""
+			}
+			if number, ok := parseNumberWords(words, reduction); ok {
+				fmt.Fprintf(&restored, "%0*d", digits, number)
+			} else {
+				restored.WriteString(words)
+			}
+			continue
+		}
+
+		l, ok := next(1)
+		if !ok {
+			return fmt.Errorf("The format mask does not fit the plaintext"), ""
+		}
+		switch mark {
+		case maskLower:
+			restored.WriteString(strings.ToLower(l))
+		case maskUpper:
+			restored.WriteString(l)
+		case maskExcludedLower:
+			restored.WriteRune(unicode.ToLower(reduction.Excluded))
+		case maskExcludedUpper:
+			restored.WriteRune(reduction.Excluded)
+		default:
+			return fmt.Errorf("The format mask is not valid"), ""
+		}
+	}
+
+	if letters != "" {
+		return fmt.Errorf("The format mask does not fit the plaintext"), ""
+	}
+	return nil, restored.String()
+}
+
+// Marks of the template of a format mask, each standing for one symbol of
+// the plaintext but for an omitted excluded letter, which stands for none, a
+// literal, which is followed by the rune it keeps, and a number, which is
+// followed by <digits>:<letters>; giving the number of digits written and
+// letters spelling them.
+const (
+	maskLower         = 'a'
+	maskUpper         = 'A'
+	maskExcludedLower = 'e'
+	maskExcludedUpper = 'E'
+	maskOmittedLower  = 'o'
+	maskOmittedUpper  = 'O'
+	maskLiteral       = '\\'
+	maskNumber        = '#'
+)
+
+// formatMask records, while plaintext is prepared, what each part of it
+// became: a symbol of the grid in some case, a replaced or omitted excluded
+// letter, a spelled out number or a literal left out. Every method does nothing on a
+// nil mask, so preparing plaintext without one costs nothing.
+type formatMask struct {
+	template strings.Builder
+}
+
+func (mask *formatMask) symbol(l rune) {
+	if mask == nil {
+		return
+	}
+	if unicode.IsLower(l) {
+		mask.template.WriteByte(maskLower)
+	} else {
+		mask.template.WriteByte(maskUpper)
+	}
+}
+
+func (mask *formatMask) excluded(l rune) {
+	if mask == nil {
+		return
+	}
+	if unicode.IsLower(l) {
+		mask.template.WriteByte(maskExcludedLower)
+	} else {
+		mask.template.WriteByte(maskExcludedUpper)
+	}
+}
+
+func (mask *formatMask) omitted(l rune) {
+	if mask == nil {
+		return
+	}
+	if unicode.IsLower(l) {
+		mask.template.WriteByte(maskOmittedLower)
+	} else {
+		mask.template.WriteByte(maskOmittedUpper)
+	}
+}
+
+func (mask *formatMask) literal(l rune) {
+	if mask == nil {
+		return
+	}
+	mask.template.WriteByte(maskLiteral)
+	mask.template.WriteRune(l)
+}
+
+func (mask *formatMask) number(digits int, letters int) {
+	if mask == nil {
+		return
+	}
+	fmt.Fprintf(&mask.template, "%c%d:%d;", maskNumber, digits, letters)
+}
+
+// finish returns the mask as the cells of prepared holding separators or
+// padding, found by lining it up with letters, then the template.
+func (mask *formatMask) finish(letters string, prepared string) string {
+	if mask == nil {
+		return ""
+	}
+
+	// Separators never stand where the next letter would, so any mismatch
+	// is one
+	var nulls []string
+	for i, j := 0, 0; i < len(prepared); i++ {
+		if j < len(letters) && prepared[i] == letters[j] {
+			j++
+		} else {
+			nulls = append(nulls, strconv.Itoa(i))
+		}
+	}
+
+	return strings.Join(nulls, ",") + "|" + mask.template.String()
+}
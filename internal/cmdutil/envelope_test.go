@@ -0,0 +1,60 @@
+package cmdutil
+
+import (
+	"playfaircrack/internal/cipher"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelope(t *testing.T) {
+	plaintext := `Jack said: "Meet me at 10:05, Room 007 -- bring 21 jolly balloons!"  OK?`
+	tests := []struct {
+		name       string
+		grid       string
+		reduction  Reduction
+		convention cipher.Convention
+		period     int
+	}{
+		{name: "classic", grid: "5x5", reduction: Reduction{Excluded: 'J', Replacement: 'I'}},
+		{name: "omit", grid: "5x5", reduction: Reduction{Excluded: 'Q'}, period: 5},
+		{name: "omit excluded", grid: "5x5", reduction: Reduction{Excluded: 'J'}},
+		{name: "alphanumeric", grid: "6x6", convention: cipher.QX_CONVENTION},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err, layout := ValidateAndTransformLayout(tt.grid, "", tt.reduction)
+			assert.NoError(t, err)
+			keys := [2]cipher.Key{layout.KeywordKey("playfair example")}
+
+			err, prepared, mask := ValidateAndTransformEnvelopePlaintext(plaintext, layout, tt.reduction, 'X', tt.convention, tt.period)
+			assert.NoError(t, err)
+			// No letter of the plaintext is kept as a literal, omitted ones
+			// included
+			assert.NotRegexp(t, `\\\pL`, mask)
+			ciphertext := cipher.PLAYFAIR.Encrypt([]byte(prepared), keys, tt.period, cipher.STANDARD_RULES)
+
+			text := Envelope{Ciphertext: string(ciphertext), Mask: mask}.String()
+			assert.True(t, IsEnvelope(text))
+			err, sealed := ValidateAndTransformEnvelope(text)
+			assert.NoError(t, err)
+			assert.Equal(t, string(ciphertext), sealed.Ciphertext)
+
+			decrypted := cipher.PLAYFAIR.Decrypt([]byte(sealed.Ciphertext), keys, tt.period, cipher.STANDARD_RULES)
+			err, restored := sealed.Restore(decrypted, tt.reduction)
+			assert.NoError(t, err)
+			assert.Equal(t, plaintext, restored)
+		})
+	}
+
+	// A mask for another plaintext does not fit
+	err, _, mask := ValidateAndTransformEnvelopePlaintext("short", cipher.StandardLayout('J'), Reduction{Excluded: 'J', Replacement: 'I'}, 'X', cipher.CLASSIC_CONVENTION, 0)
+	assert.NoError(t, err)
+	err, _ = Envelope{Mask: mask}.Restore([]byte("MUCHLONGER"), Reduction{Excluded: 'J', Replacement: 'I'})
+	assert.Error(t, err)
+
+	err, _ = ValidateAndTransformEnvelope("PFE1.ABCD")
+	assert.Error(t, err)
+	assert.False(t, IsEnvelope("ABCD"))
+}
@@ -0,0 +1,203 @@
+package cmdutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/divan/num2words"
+)
+
+// MAX_NUMBER is the largest number num2words can spell out.
+const MAX_NUMBER = 999_999_999_999
+
+// numberValues are the words num2words spells numbers with, in upper case,
+// along with their value. Scales are given as negative powers of ten to tell
+// them apart from plain values.
+var numberValues = map[string]int{
+	"ZERO": 0, "ONE": 1, "TWO": 2, "THREE": 3, "FOUR": 4,
+	"FIVE": 5, "SIX": 6, "SEVEN": 7, "EIGHT": 8, "NINE": 9,
+	"TEN": 10, "ELEVEN": 11, "TWELVE": 12, "THIRTEEN": 13, "FOURTEEN": 14,
+	"FIFTEEN": 15, "SIXTEEN": 16, "SEVENTEEN": 17, "EIGHTEEN": 18, "NINETEEN": 19,
+	"TWENTY": 20, "THIRTY": 30, "FORTY": 40, "FIFTY": 50,
+	"SIXTY": 60, "SEVENTY": 70, "EIGHTY": 80, "NINETY": 90,
+	"HUNDRED": -2, "THOUSAND": -3, "MILLION": -6, "BILLION": -9,
+}
+
+// fold writes text in the letters of the grid, replacing Excluded with
+// Replacement or dropping it, and anything but A-Z.
+func (reduction Reduction) fold(text string) string {
+	return strings.Map(func(l rune) rune {
+		if l == reduction.Excluded {
+			if reduction.Replacement == 0 {
+				return -1
+			}
+			return reduction.Replacement
+		}
+		if l < 'A' || l > 'Z' {
+			return -1
+		}
+		return l
+	}, strings.ToUpper(text))
+}
+
+// numberWords spells out the number written in digits as the letters of the
+// grid, the way plaintext numbers are encrypted.
+func numberWords(digits string, reduction Reduction) (error, string) {
+	number, err := strconv.Atoi(digits)
+	if err != nil || number > MAX_NUMBER {
+		return fmt.Errorf("Numbers must be written in 0-9 and be at most %d, got %s", MAX_NUMBER, digits), ""
+	}
+	return nil, reduction.fold(num2words.Convert(number))
+}
+
+// parseNumberWords reads letters spelling out a whole number as numberWords
+// does, reporting false when they spell out anything else.
+func parseNumberWords(letters string, reduction Reduction) (int, bool) {
+	if letters == "" {
+		return 0, false
+	}
+
+	// Words can hide inside longer ones, FOUR in FOURTEEN, so try every way
+	// of splitting letters until one spells a number back exactly
+	var split func(rest string, words []int) (int, bool)
+	split = func(rest string, words []int) (int, bool) {
+		if rest == "" {
+			number, ok := numberValue(words)
+			if !ok {
+				return 0, false
+			}
+			_, spelled := numberWords(strconv.Itoa(number), reduction)
+			return number, spelled == letters
+		}
+		for word, value := range numberValues {
+			folded := reduction.fold(word)
+			if folded != "" && strings.HasPrefix(rest, folded) {
+				if number, ok := split(rest[len(folded):], append(words, value)); ok {
+					return number, true
+				}
+			}
+		}
+		return 0, false
+	}
+	return split(letters, nil)
+}
+
+// numberValue adds up the values of a sequence of number words, reporting
+// false when no number could be written that way.
+func numberValue(words []int) (int, bool) {
+	total, group := 0, 0
+	for _, value := range words {
+		switch {
+		case value == -2:
+			group *= 100
+		case value < 0:
+			scale := 1
+			for range -value {
+				scale *= 10
+			}
+			total += group * scale
+			group = 0
+		default:
+			group += value
+		}
+	}
+	number := total + group
+	return number, number >= 0 && number <= MAX_NUMBER
+}
+
+// NumbersToDigits returns words with every run of them spelling out a number
+// replaced by its digits, reporting whether there were any. Runs spell numbers
+// as encrypting plaintext does, or with AND after the hundreds, and the words
+// can split them anywhere.
+func NumbersToDigits(words []string, reduction Reduction) ([]string, bool) {
+	var digits []string
+	found := false
+	for i := 0; i < len(words); {
+		// The longest run of number words, which must not start or end on AND
+		var runs [][]string
+		for _, word := range words[i:] {
+			parts, ok := splitNumberWords(word, reduction)
+			if !ok {
+				break
+			}
+			runs = append(runs, parts)
+		}
+		for len(runs) > 0 && onlyAnd(runs[len(runs)-1], reduction) {
+			runs = runs[:len(runs)-1]
+		}
+
+		// Fall back on shorter runs until one spells a number exactly
+		n := 0
+		if len(runs) > 0 && !onlyAnd(runs[0], reduction) {
+			for n = len(runs); n > 0; n-- {
+				var letters strings.Builder
+				for _, parts := range runs[:n] {
+					for _, part := range parts {
+						if part != reduction.fold("AND") {
+							letters.WriteString(part)
+						}
+					}
+				}
+				if number, ok := parseNumberWords(letters.String(), reduction); ok && !onlyAnd(runs[n-1], reduction) {
+					digits = append(digits, strconv.Itoa(number))
+					break
+				}
+			}
+		}
+
+		if n == 0 {
+			digits = append(digits, words[i])
+			i++
+		} else {
+			found = true
+			i += n
+		}
+	}
+	return digits, found
+}
+
+// splitNumberWords splits word into the number words, and ANDs, it is made
+// of, folded by reduction, reporting false when it is anything else.
+func splitNumberWords(word string, reduction Reduction) ([]string, bool) {
+	word = reduction.fold(word)
+	if word == "" {
+		return nil, false
+	}
+
+	var split func(rest string) ([]string, bool)
+	split = func(rest string) ([]string, bool) {
+		if rest == "" {
+			return nil, true
+		}
+		try := func(part string) ([]string, bool) {
+			folded := reduction.fold(part)
+			if folded == "" || !strings.HasPrefix(rest, folded) {
+				return nil, false
+			}
+			parts, ok := split(rest[len(folded):])
+			return append([]string{folded}, parts...), ok
+		}
+
+		if parts, ok := try("AND"); ok {
+			return parts, true
+		}
+		for number := range numberValues {
+			if parts, ok := try(number); ok {
+				return parts, true
+			}
+		}
+		return nil, false
+	}
+	return split(word)
+}
+
+// onlyAnd reports whether parts are nothing but ANDs.
+func onlyAnd(parts []string, reduction Reduction) bool {
+	for _, part := range parts {
+		if part != reduction.fold("AND") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package cmdutil
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNumberWords(t *testing.T) {
+	reduction := Reduction{Excluded: 'J', Replacement: 'I'}
+	for _, number := range []int{0, 7, 14, 21, 40, 100, 115, 999, 1000, 1014, 20_000_017, MAX_NUMBER} {
+		_, words := numberWords(strconv.Itoa(number), reduction)
+		parsed, ok := parseNumberWords(words, reduction)
+		assert.True(t, ok, words)
+		assert.Equal(t, number, parsed)
+	}
+
+	// Omitted letters are missing from the words too
+	omitted := Reduction{Excluded: 'E'}
+	_, words := numberWords("1017", omitted)
+	assert.Equal(t, "ONTHOUSANDSVNTN", words)
+	parsed, ok := parseNumberWords(words, omitted)
+	assert.True(t, ok)
+	assert.Equal(t, 1017, parsed)
+
+	for _, letters := range []string{"", "HUNDRED", "ONEONE", "TWENTYTEN", "BALLOON"} {
+		_, ok := parseNumberWords(letters, reduction)
+		assert.False(t, ok, letters)
+	}
+}
+
+func TestNumbersToDigits(t *testing.T) {
+	reduction := Reduction{Excluded: 'J', Replacement: 'I'}
+	tests := []struct {
+		words  string
+		digits string
+	}{
+		{words: "one hundred and twenty cats", digits: "120 cats"},
+		{words: "onehundredtwenty cats and two dogs", digits: "120 cats and 2 dogs"},
+		{words: "twenty one thousand four hundred", digits: "21400"},
+		{words: "call at ten fifteen and nine", digits: "call at 10 15 and 9"},
+		{words: "one one", digits: "1 1"},
+		{words: "often bones", digits: "often bones"},
+	}
+
+	for _, tt := range tests {
+		digits, found := NumbersToDigits(strings.Fields(tt.words), reduction)
+		assert.Equal(t, tt.digits, strings.Join(digits, " "), tt.words)
+		assert.Equal(t, tt.words != tt.digits, found)
+	}
+}
@@ -2,19 +2,99 @@ package cmdutil
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
+	"playfaircrack/internal/cipher"
+	"playfaircrack/internal/crack"
 	"playfaircrack/internal/score"
 )
 
-func PrintSegmentedPlaintext(plaintext []byte, sep byte) {
+// PrintSegmentedPlaintext prints plaintext split into words, once its
+// separators are taken out, and returns the words.
+func PrintSegmentedPlaintext(plaintext []byte, sep byte, convention cipher.Convention) []string {
 	segmentor := score.GetSegmentorInstance()
 
 	// Remove playfair separator
-	filteredText := score.RemovePlayfairSep(plaintext, sep)
+	filteredText := score.RemovePlayfairSep(plaintext, sep, convention)
 
 	// Segment into words and print
 	words := segmentor.Segment(filteredText)
 	for _, word := range words {
 		fmt.Printf("%s ", word)
 	}
+	return words
+}
+
+// PrintDigitPlaintext prints words as DigitPlaintext returns them, nothing
+// when they spell out no number.
+func PrintDigitPlaintext(words []string, reduction Reduction) {
+	digits, found := DigitPlaintext(words, reduction)
+	if !found {
+		return
+	}
+	fmt.Printf("Plaintext With Digits:\n%s\n\n", digits)
+}
+
+// DigitPlaintext returns the segmented words of a plaintext with every
+// spelled out number in them written in digits, see NumbersToDigits, and
+// whether there was any.
+func DigitPlaintext(words []string, reduction Reduction) (string, bool) {
+	digits, found := NumbersToDigits(words, reduction)
+	return strings.Join(digits, " "), found
+}
+
+// PrintCleanedPlaintext prints plaintext as CleanedPlaintext returns it.
+func PrintCleanedPlaintext(plaintext []byte, sep byte, convention cipher.Convention, period int, layout cipher.Layout, reduction Reduction) {
+	fmt.Printf("Cleaned Plaintext:\n%s\n", CleanedPlaintext(plaintext, sep, convention, period, layout, reduction))
+}
+
+// CleanedPlaintext returns plaintext with its separators and padding taken
+// out and the excluded letter of the reduction written back, see
+// score.CleanPlaintext. Only 5x5 grids of letters leave a letter out.
+func CleanedPlaintext(plaintext []byte, sep byte, convention cipher.Convention, period int, layout cipher.Layout, reduction Reduction) string {
+	var excluded, replacement byte
+	if layout.IsStandard() {
+		excluded, replacement = byte(reduction.Excluded), byte(reduction.Replacement)
+	}
+	return score.CleanPlaintext(plaintext, sep, convention, period, excluded, replacement)
+}
+
+func PrintCandidates(candidates []crack.Candidate) {
+	fmt.Printf("Top %d Candidates:\n", len(candidates))
+	for i, candidate := range candidates {
+		key := candidate.Key
+		if candidate.Period > 1 {
+			key = fmt.Sprintf("%s (period %d)", key, candidate.Period)
+		}
+		fmt.Printf("%3d %-4.4f %6.2f%% %s %s\n", i+1, candidate.Score, 100.0*candidate.PercentEnglish, key, strings.Join(candidate.SegmentedText, " "))
+	}
+}
+
+// PrintKeys prints every key cipherType takes, in order.
+func PrintKeys(keys [2]cipher.Key, cipherType cipher.Type) {
+	if cipherType.Grids() == 1 {
+		fmt.Printf("With Key: %s\n\n", keys[0])
+		return
+	}
+
+	fmt.Printf("With Keys:")
+	for _, key := range keys[:cipherType.Grids()] {
+		fmt.Printf(" %s", key)
+	}
+	fmt.Printf("\n\n")
+}
+
+// PrintGrid prints a 25 letter key as a 5x5 grid, showing unknown cells as ?.
+func PrintGrid(key string, unknown []int) {
+	for i := 0; i < len(key); i++ {
+		if slices.Contains(unknown, i) {
+			fmt.Printf("? ")
+		} else {
+			fmt.Printf("%c ", key[i])
+		}
+		if i%5 == 4 {
+			fmt.Printf("\n")
+		}
+	}
 }
@@ -5,11 +5,10 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"playfaircrack/internal/cipher"
 	"strconv"
 	"strings"
 	"unicode"
-
-	"github.com/divan/num2words"
 )
 
 func GatherInput(filepath string) (error, string) {
@@ -57,50 +56,89 @@ func GatherInput(filepath string) (error, string) {
 	return nil, text
 }
 
-func ValidateAndTransformKey(key string, excludedLetter rune) (error, [25]byte) {
-	outKey := new([25]byte)
+// Reduction fits the 26 letters of the alphabet into the 25 cells of the
+// grid, by replacing Excluded with Replacement wherever it appears, or by
+// dropping it when Replacement is 0.
+type Reduction struct {
+	Excluded    rune
+	Replacement rune
+}
 
-	if key != "" {
-		key = strings.ToUpper(key)
-		var transformed strings.Builder
-		for _, l := range key {
-			if l == excludedLetter || strings.ContainsRune(transformed.String(), l) {
-				continue
-			}
-			transformed.WriteRune(l)
+// ValidateAndTransformReduction reads the reduction rule from a pair of
+// letters to merge, the second folded into the first as in IJ or CK, or a
+// single letter to omit. With neither J is folded into I.
+func ValidateAndTransformReduction(merge string, omit string) (error, Reduction) {
+	merge, omit = strings.ToUpper(merge), strings.ToUpper(omit)
+	isLetter := func(l rune) bool { return 'A' <= l && l <= 'Z' }
+
+	switch {
+	case merge != "" && omit != "":
+		return fmt.Errorf("Letters can either be merged or omitted, not both"), Reduction{}
+	case merge != "":
+		letters := []rune(merge)
+		if len(letters) != 2 || !isLetter(letters[0]) || !isLetter(letters[1]) || letters[0] == letters[1] {
+			return fmt.Errorf("Merged letters must be two different letters like IJ, got %s", merge), Reduction{}
+		}
+		return nil, Reduction{Excluded: letters[1], Replacement: letters[0]}
+	case omit != "":
+		letters := []rune(omit)
+		if len(letters) != 1 || !isLetter(letters[0]) {
+			return fmt.Errorf("The omitted letter must be a single letter like Q, got %s", omit), Reduction{}
 		}
+		return nil, Reduction{Excluded: letters[0]}
+	}
 
-		for _, l := range "ABCDEFGHIJKLMNOPQRSTUVWXYZ" {
-			if l == excludedLetter || strings.ContainsRune(transformed.String(), l) {
-				continue
-			}
+	return nil, Reduction{Excluded: 'J', Replacement: 'I'}
+}
 
-			transformed.WriteRune(l)
+func ValidateAndTransformKey(key string, exc, rep rune) (error, cipher.Key) {
+	if key != "" {
+		if rep != 0 && !strings.HasPrefix(strings.TrimSpace(key), "{") {
+			key = strings.ReplaceAll(strings.ToUpper(key), string(exc), string(rep))
 		}
-
-		key = transformed.String()
-	} else {
-		var byteKey []byte
-		letters := []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ")
-		for _, l := range letters {
-			if l != byte(excludedLetter) {
-				byteKey = append(byteKey, l)
-			}
+		validKey, err := cipher.ParseKey(key, byte(exc))
+		if err == nil && validKey.Excluded() != byte(exc) {
+			err = fmt.Errorf("The key leaves out %c rather than %c", validKey.Excluded(), exc)
 		}
-		rand.Shuffle(len(byteKey), func(i, j int) { byteKey[i], byteKey[j] = byteKey[j], byteKey[i] })
+		return err, validKey
+	}
 
-		key = string(byteKey)
+	var byteKey []byte
+	letters := []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	for _, l := range letters {
+		if l != byte(exc) {
+			byteKey = append(byteKey, l)
+		}
 	}
+	rand.Shuffle(len(byteKey), func(i, j int) { byteKey[i], byteKey[j] = byteKey[j], byteKey[i] })
 
-	if len(key) != 25 {
-		panic("Transformed key is not of length 25")
+	return nil, cipher.NewKey([25]byte(byteKey), byte(exc))
+}
+
+// ValidateAndTransformGrid reads a full 25 letter key grid, row major, with
+// whitespace between letters ignored.
+func ValidateAndTransformGrid(grid string, exc, rep rune) (error, cipher.Key) {
+	var key [25]byte
+
+	letters := []rune(strings.ToUpper(strings.Join(strings.Fields(grid), "")))
+	if len(letters) != 25 {
+		return fmt.Errorf("Key grids must have 25 letters, got %d", len(letters)), cipher.Key{}
 	}
 
-	for i, letter := range key {
-		outKey[i] = byte(letter)
+	for cell, l := range letters {
+		if l == exc {
+			l = rep
+		}
+		if l < 'A' || l > 'Z' {
+			return fmt.Errorf("Key grids must only hold letters other than %c, %c", exc, letters[cell]), cipher.Key{}
+		}
+		if strings.ContainsRune(string(key[:cell]), l) {
+			return fmt.Errorf("The letter %c appears more than once in the key grid", l), cipher.Key{}
+		}
+		key[cell] = byte(l)
 	}
 
-	return nil, *outKey
+	return nil, cipher.NewKey(key, byte(exc))
 }
 
 func ValidateAndTransformCiphertext(ciphertext string, excludedLetter rune) (error, string) {
@@ -135,76 +173,359 @@ func ValidateAndTransformCiphertext(ciphertext string, excludedLetter rune) (err
 	return nil, string(transformed)
 }
 
-func ValidateAndTransformPlaintext(plaintext string, exc, rep, sep rune) (error, string) {
+func ValidateAndTransformCrib(crib string, exc, rep rune) (error, string) {
 	// Ensure uppercase
-	plaintext = strings.ToUpper(plaintext)
+	crib = strings.ToUpper(crib)
 
-	// Handle converting all to letters
 	var builder strings.Builder
-	var number []rune
-	for _, l := range plaintext {
+	for _, l := range crib {
 		// Skip whitespace
 		if unicode.IsSpace(l) || unicode.IsPunct(l) {
 			continue
 		}
 
-		// Handle numbers
-		if unicode.IsNumber(l) {
-			number = append(number, l)
-		} else if len(number) != 0 {
-			// We have a full text number
-			num, err := strconv.Atoi(string(number))
-			if err != nil {
-				number = number[:0]
+		// Ensure letter
+		if l < 'A' || l > 'Z' {
+			return fmt.Errorf("Cribs must not contain any non-letters, %c", l), ""
+		}
+
+		// Replace excludedLetters with replacements
+		if l == exc {
+			if rep == 0 {
 				continue
 			}
+			l = rep
+		}
+
+		builder.WriteRune(l)
+	}
+
+	if builder.Len() < 2 {
+		return fmt.Errorf("Cribs must be at least two letters long"), ""
+	}
+
+	return nil, builder.String()
+}
+
+// ValidateAndTransformPins reads pinned key cells, either as a list of
+// letter@cell pairs such as "P@0,L@1" with cells numbered row major from 0,
+// or as a 25 character grid with ?, ., _ or * for the free cells.
+func ValidateAndTransformPins(fix string, exc, rep rune) (error, cipher.PinnedCells) {
+	var pins cipher.PinnedCells
+
+	pin := func(l rune, cell int) error {
+		l = unicode.ToUpper(l)
+		if l == exc {
+			if rep == 0 {
+				return fmt.Errorf("Pinned cells must not hold %c, the omitted letter", l)
+			}
+			l = rep
+		}
+		if l < 'A' || l > 'Z' {
+			return fmt.Errorf("Pinned cells must hold letters, %c", l)
+		}
+		if pins.IsPinned(byte(l)) {
+			return fmt.Errorf("The letter %c is pinned more than once", l)
+		}
+		if pins[cell] != 0 {
+			return fmt.Errorf("The cell %d is pinned more than once", cell)
+		}
+		pins[cell] = byte(l)
+		return nil
+	}
+
+	if strings.ContainsRune(fix, '@') {
+		for _, pair := range strings.Split(fix, ",") {
+			letter, cellText, found := strings.Cut(strings.TrimSpace(pair), "@")
+			cell, err := strconv.Atoi(cellText)
+			if !found || len([]rune(letter)) != 1 || err != nil {
+				return fmt.Errorf("Pinned cells must look like LETTER@CELL, %s", pair), pins
+			}
+			if cell < 0 || cell >= 25 {
+				return fmt.Errorf("Pinned cells must be in 0-24, %d", cell), pins
+			}
+			if err := pin([]rune(letter)[0], cell); err != nil {
+				return err, pins
+			}
+		}
+
+		return nil, pins
+	}
 
-			// Convert to string
-			numberString := num2words.Convert(num)
+	grid := strings.Join(strings.Fields(fix), "")
+	if len([]rune(grid)) != 25 {
+		return fmt.Errorf("Pinned grids must have 25 cells, got %d", len([]rune(grid))), pins
+	}
+	for cell, l := range []rune(grid) {
+		if strings.ContainsRune("?._*", l) {
+			continue
+		}
+		if err := pin(l, cell); err != nil {
+			return err, pins
+		}
+	}
 
-			// Remove whitespace and uppercase
-			numberString = strings.ReplaceAll(numberString, " ", "")
-			numberString = strings.ToUpper(numberString)
+	return nil, pins
+}
 
-			// Write and reset
-			builder.WriteString(numberString)
-			number = number[:0]
+// ValidateAndTransformPlaintext turns plaintext into letters ready for
+// PlayfairEncrypt, split and padded under the classic convention.
+func ValidateAndTransformPlaintext(plaintext string, exc, rep, sep rune) (error, string) {
+	err, letters := plaintextLetters(plaintext, exc, rep, sep, nil)
+	if err != nil {
+		return err, ""
+	}
+
+	prepared, err := cipher.CLASSIC_CONVENTION.Prepare([]byte(letters), byte(sep))
+	if err != nil {
+		return err, ""
+	}
+	return nil, string(prepared)
+}
+
+// plaintextLetters turns plaintext into the letters to encrypt, before any
+// letter pairs are split, numbers being spelled out. Everything else goes into
+// mask when it is not nil, see formatMask.
+func plaintextLetters(plaintext string, exc, rep, sep rune, mask *formatMask) (error, string) {
+	if sep == exc {
+		return fmt.Errorf("The separator %c must not be the excluded letter", sep), ""
+	}
+	reduction := Reduction{Excluded: exc, Replacement: rep}
+
+	// Handle converting all to letters
+	var builder strings.Builder
+	var number []rune
+	writeNumber := func() error {
+		if len(number) == 0 {
+			return nil
+		}
+		err, words := numberWords(string(number), reduction)
+		if err != nil {
+			return err
+		}
+		builder.WriteString(words)
+		mask.number(len(number), len(words))
+		number = number[:0]
+		return nil
+	}
+
+	for _, l := range plaintext {
+		// Handle numbers
+		if unicode.IsNumber(l) {
+			number = append(number, l)
+			continue
+		}
+		if err := writeNumber(); err != nil {
+			return err, ""
+		}
+
+		// Skip whitespace
+		if unicode.IsSpace(l) || unicode.IsPunct(l) {
+			mask.literal(l)
+			continue
 		}
 
 		// Ensure letter
 		if !unicode.IsLetter(l) {
-			return fmt.Errorf("Ciphertexts must not contain any non-letters, %c", l), ""
+			return fmt.Errorf("Plaintexts must not contain any non-letters, %c", l), ""
 		}
 
 		// Replace excludedLetters with replacements
-		if l == exc {
-			l = rep
+		upper := unicode.ToUpper(l)
+		if upper == exc {
+			if rep == 0 {
+				mask.omitted(l)
+				continue
+			}
+			mask.excluded(l)
+			upper = rep
+		} else {
+			mask.symbol(l)
 		}
 
-		builder.WriteRune(l)
+		builder.WriteRune(upper)
+	}
+	if err := writeNumber(); err != nil {
+		return err, ""
 	}
 
-	// Playfair transformation
-	var prevL rune
-	var i int = 0
-	var transformed string = builder.String()
-	builder.Reset()
-	for _, l := range transformed {
-		// Ensure no pairs
-		if i%2 == 1 && l == prevL {
-			builder.WriteRune(sep)
-			i++
+	return nil, builder.String()
+}
+
+// ValidateAndTransformLayout reads the grid shape and alphabet. A 5x5 grid
+// with no alphabet leaves out the excluded letter of the reduction, any other
+// grid leaves no letter out and so takes no reduction.
+func ValidateAndTransformLayout(grid string, alphabet string, reduction Reduction) (error, cipher.Layout) {
+	layout, err := cipher.ParseLayout(grid, alphabet, byte(reduction.Excluded))
+	if err != nil {
+		return err, cipher.Layout{}
+	}
+	if layout.IsStandard() && layout.Excluded() != byte(reduction.Excluded) {
+		return fmt.Errorf("The alphabet leaves out %c rather than %c", layout.Excluded(), reduction.Excluded), cipher.Layout{}
+	}
+	return nil, layout
+}
+
+// ValidateAndTransformLayoutKey reads a key for the layout as
+// ValidateAndTransformKey does, picking a random one when key is empty.
+func ValidateAndTransformLayoutKey(key string, layout cipher.Layout, reduction Reduction) (error, cipher.Key) {
+	if layout.IsStandard() {
+		return ValidateAndTransformKey(key, reduction.Excluded, reduction.Replacement)
+	}
+	if key == "" {
+		return nil, layout.RandomKey(rand.New(rand.NewSource(rand.Int63())))
+	}
+
+	validKey, err := layout.ParseKey(key)
+	return err, validKey
+}
+
+// ValidateAndTransformKeys reads a key for every grid of cipherType, see
+// ValidateAndTransformLayoutKey. When required every one of them must be
+// given, otherwise missing keys are picked at random.
+func ValidateAndTransformKeys(keys []string, cipherType cipher.Type, layout cipher.Layout, reduction Reduction, required bool) (error, [2]cipher.Key) {
+	var validKeys [2]cipher.Key
+	for i, key := range keys {
+		if i >= cipherType.Grids() {
+			if key != "" {
+				return fmt.Errorf("The %s cipher takes %d key(s), got %d", cipherType, cipherType.Grids(), i+1), validKeys
+			}
+			continue
+		}
+		if key == "" && required {
+			return fmt.Errorf("The %s cipher needs %d keys", cipherType, cipherType.Grids()), validKeys
 		}
 
-		builder.WriteRune(l)
-		prevL = l
-		i++
+		var err error
+		err, validKeys[i] = ValidateAndTransformLayoutKey(key, layout, reduction)
+		if err != nil {
+			return err, validKeys
+		}
 	}
 
-	// Ensure alignment
-	if builder.Len()%2 != 0 {
-		builder.WriteRune(sep)
+	return nil, validKeys
+}
+
+// ValidateAndTransformRules reads the name of the rule profile cipherType
+// maps digraphs by, only Playfair takes rules other than the standard ones.
+func ValidateAndTransformRules(name string, cipherType cipher.Type) (error, cipher.Rules) {
+	rules, err := cipher.ParseRules(name)
+	if err != nil {
+		return err, rules
+	}
+	if rules != cipher.STANDARD_RULES && cipherType != cipher.PLAYFAIR {
+		return fmt.Errorf("The %s cipher only takes the standard rules, got %s", cipherType, rules), rules
 	}
 
-	return nil, builder.String()
+	return nil, rules
+}
+
+// ValidateAndTransformLayoutCiphertext checks ciphertext holds only symbols
+// of the layout, as ValidateAndTransformCiphertext does for letters. Doubled
+// digraphs, taken down the columns for a Seriated Playfair period above 1,
+// are only allowed with doubles, for the ciphers that can encrypt to them.
+func ValidateAndTransformLayoutCiphertext(ciphertext string, layout cipher.Layout, doubles bool, period int) (error, string) {
+	if layout.IsStandard() && !doubles && period <= 1 {
+		return ValidateAndTransformCiphertext(ciphertext, rune(layout.Excluded()))
+	}
+	if len(ciphertext)%2 != 0 {
+		return fmt.Errorf("Ciphertexts must be aligned on the two letter boundary"), ""
+	}
+
+	ciphertext = strings.ToUpper(ciphertext)
+	for i := 0; i < len(ciphertext); i++ {
+		if !layout.Contains(ciphertext[i]) {
+			return fmt.Errorf("Ciphertexts must only contain symbols of the grid, %c", ciphertext[i]), ""
+		}
+		if !doubles && period <= 1 && i%2 == 1 && ciphertext[i] == ciphertext[i-1] {
+			return fmt.Errorf("Ciphertexts must not contain symbol pairs aligned on the two letter boundary, %c%c", ciphertext[i], ciphertext[i]), ""
+		}
+	}
+	if !doubles && period > 1 && cipher.HasDoubledColumns([]byte(ciphertext), period) {
+		return fmt.Errorf("Ciphertexts must not hold the same symbol twice in a column of period %d", period), ""
+	}
+
+	return nil, ciphertext
+}
+
+// ValidateAndTransformLayoutPlaintext prepares plaintext for the layout as
+// ValidateAndTransformPlaintext does, splitting doubled letters under
+// convention. Grids other than the 5x5 one keep digits and any other symbols
+// of their alphabet as they are, skipping whitespace and punctuation outside
+// it. A Seriated Playfair period above 1 splits doubled columns rather than
+// letter pairs, under the classic convention only.
+func ValidateAndTransformLayoutPlaintext(plaintext string, layout cipher.Layout, reduction Reduction, sep rune, convention cipher.Convention, period int) (error, string) {
+	err, prepared, _ := layoutPlaintext(plaintext, layout, reduction, sep, convention, period, nil)
+	return err, prepared
+}
+
+// ValidateAndTransformEnvelopePlaintext prepares plaintext as
+// ValidateAndTransformLayoutPlaintext does, also returning the format mask
+// restoring plaintext from the prepared text, see Envelope.
+func ValidateAndTransformEnvelopePlaintext(plaintext string, layout cipher.Layout, reduction Reduction, sep rune, convention cipher.Convention, period int) (error, string, string) {
+	return layoutPlaintext(plaintext, layout, reduction, sep, convention, period, &formatMask{})
+}
+
+// layoutPlaintext prepares plaintext for ValidateAndTransformLayoutPlaintext,
+// writing everything the prepared text leaves out into mask when it is not
+// nil, and returning the finished mask.
+func layoutPlaintext(plaintext string, layout cipher.Layout, reduction Reduction, sep rune, convention cipher.Convention, period int, mask *formatMask) (error, string, string) {
+	if sep >= 128 || !layout.Contains(byte(sep)) {
+		return fmt.Errorf("The separator %c must be in the alphabet of the grid", sep), "", ""
+	}
+	null := convention.Null(byte(sep))
+	if convention != cipher.CLASSIC_CONVENTION && !layout.Contains(null) {
+		return fmt.Errorf("The %s convention splits doubled separators with %c, which is not in the alphabet of the grid", convention, null), "", ""
+	}
+	if convention != cipher.CLASSIC_CONVENTION && period > 1 {
+		return fmt.Errorf("Seriated Playfair only takes the classic convention"), "", ""
+	}
+
+	var letters string
+	if layout.IsStandard() {
+		var err error
+		err, letters = plaintextLetters(plaintext, reduction.Excluded, reduction.Replacement, sep, mask)
+		if err != nil {
+			return err, "", ""
+		}
+	} else {
+		var builder strings.Builder
+		for _, l := range plaintext {
+			upper := unicode.ToUpper(l)
+			if upper < 128 && layout.Contains(byte(upper)) {
+				builder.WriteRune(upper)
+				mask.symbol(l)
+			} else if unicode.IsSpace(l) || unicode.IsPunct(l) {
+				mask.literal(l)
+			} else {
+				return fmt.Errorf("Plaintexts must only contain symbols of the grid, %c", l), "", ""
+			}
+		}
+		letters = builder.String()
+	}
+
+	err, prepared := separate(letters, sep, convention, period)
+	if err != nil {
+		return err, "", ""
+	}
+
+	// The classic convention only needs its null to pad after a last separator
+	if !layout.Contains(null) && strings.IndexByte(prepared, null) >= 0 {
+		return fmt.Errorf("The %s convention pads after a last separator with %c, which is not in the alphabet of the grid, try another convention", convention, null), "", ""
+	}
+	return nil, prepared, mask.finish(letters, prepared)
+}
+
+// separate splits letter pairs under convention, or doubled columns with
+// cipher.SeparateColumns for a period above 1.
+func separate(letters string, sep rune, convention cipher.Convention, period int) (error, string) {
+	if period > 1 {
+		return nil, string(cipher.SeparateColumns([]byte(letters), byte(sep), period))
+	}
+
+	prepared, err := convention.Prepare([]byte(letters), byte(sep))
+	if err != nil {
+		return err, ""
+	}
+	return nil, string(prepared)
 }
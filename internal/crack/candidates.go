@@ -0,0 +1,81 @@
+package crack
+
+import (
+	"playfaircrack/internal/cipher"
+	"slices"
+	"sync"
+)
+
+// Candidate is one of the best distinct keys found during a crack.
+type Candidate struct {
+	Key            string
+	Period         int
+	Score          float64
+	PercentEnglish float64
+	Plaintext      string
+	SegmentedText  []string
+}
+
+// leaderboard keeps the size best keys offered by every worker of every pool,
+// no two of them equivalent under cipherType, sorted by descending score.
+type leaderboard struct {
+	size       int
+	cipherType cipher.Type
+	keys       []keyData
+	lock       sync.Mutex
+}
+
+func newLeaderboard(size int, cipherType cipher.Type) *leaderboard {
+	return &leaderboard{
+		size:       size,
+		cipherType: cipherType,
+		keys:       make([]keyData, 0, size+1),
+	}
+}
+
+func (board *leaderboard) offer(candidate keyData) {
+	board.lock.Lock()
+	defer board.lock.Unlock()
+
+	// An empty board takes nothing
+	if board.size == 0 {
+		return
+	}
+	// Too low to make the board
+	if len(board.keys) == board.size && candidate.score <= board.keys[len(board.keys)-1].score {
+		return
+	}
+
+	for _, keyData := range board.keys {
+		if keyData.keys.equivalent(candidate.keys, board.cipherType) {
+			return
+		}
+	}
+
+	i := len(board.keys)
+	for i > 0 && board.keys[i-1].score < candidate.score {
+		i--
+	}
+	board.keys = slices.Insert(board.keys, i, candidate)
+	if len(board.keys) > board.size {
+		board.keys = board.keys[:board.size]
+	}
+}
+
+// candidates scores every key on the board with the slow English check.
+func (board *leaderboard) candidates(globalData *globalData) []Candidate {
+	board.lock.Lock()
+	keys := slices.Clone(board.keys)
+	board.lock.Unlock()
+
+	candidates := make([]Candidate, len(keys))
+	for i, keyData := range keys {
+		plaintext := globalData.decrypt(keyData.keys)
+		candidates[i].Key = keyData.keys.String()
+		candidates[i].Period = keyData.keys.period
+		candidates[i].Score = keyData.score
+		candidates[i].Plaintext = string(plaintext)
+		candidates[i].PercentEnglish, candidates[i].SegmentedText = globalData.scoreEnglish(plaintext)
+	}
+	return candidates
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"math/rand"
 	"playfaircrack/internal/cipher"
 	"playfaircrack/internal/score"
 	"runtime"
-	"strings"
+	"slices"
 	"sync"
 	"time"
 )
@@ -16,107 +17,345 @@ const (
 	THRESHHOLD_ENGLISH float64 = 0.9
 )
 
+// CrackResult is the outcome of a crack. Confirmed is false when the search
+// was stopped before any key passed the English check, in which case the
+// result holds the best scoring key seen across all pools. Candidates holds
+// the CrackOptions.TopN best distinct keys seen, best first. Period is the
+// Seriated Playfair period Key was found under, 0 when not seriated.
 type CrackResult struct {
 	PercentEnglish float64
+	Score          float64
 	Plaintext      string
 	SegmentedText  []string
 	Key            string
+	Period         int
+	Confirmed      bool
+	Candidates     []Candidate
+	Seed           int64
 	ElapsedTime    time.Duration
 }
 
+// globalData is read only once the workers start, all communication between
+// workers and the verifier goes through candidates and the pools.
 type globalData struct {
-	solutionChan    chan CrackResult
-	chanLock        sync.Mutex
-	cancel          context.CancelFunc
+	candidates      chan keyData
+	leaders         *leaderboard
+	crib            *cribConstraint
+	pins            *cipher.PinnedCells
+	scorer          *score.NgramScorer
+	seeds           []keyData
+	periods         []int
 	ciphertext      []byte
+	layout          cipher.Layout
 	excludedLetter  byte
 	separatorLetter byte
+	opts            CrackOptions
 }
 
+// poolData is shared by the workers of one pool. Workers exchange their
+// current keys in lockstep through runPool once per epoch, so that the keys
+// each worker sees depend only on the seeds and not on goroutine scheduling.
+// A pool searching more than one period takes turns at them, turnEpochs
+// epochs each, keeping the keys of the others parked until they are back.
 type poolData struct {
 	bestScore   float64
-	bestKey     [25]byte
+	bestKey     keySet
 	bestLock    sync.Mutex
 	currentKeys []keyData
-	currentLock sync.Mutex
+	epochs      []int
+	reports     chan keyReport
+	snapshots   []chan []keyData
+	periods     []int
+	turn        int
+	turnEpochs  int
+	parked      [][]keyData
 	global      *globalData
 }
 
 type keyData struct {
 	score float64
-	key   [25]byte
+	keys  keySet
 }
 
-func PlayfairCrack(ciphertext string, excludedLetter byte, separatorLetter byte, logVerbose bool) *CrackResult {
-	numThreads := runtime.NumCPU()
-	poolSize := 4
-	numPools := numThreads / poolSize
+type keyReport struct {
+	pid int
+	keyData
+}
+
+// PlayfairCrack searches for the key of ciphertext until a solution is
+// confirmed or ctx is done. On cancellation or deadline the best key seen so
+// far is returned unconfirmed.
+func PlayfairCrack(ctx context.Context, ciphertext string, opts CrackOptions) (*CrackResult, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	separatorLetter := opts.SeparatorLetter
+	logVerbose := opts.LogVerbose
+
+	numThreads := opts.Threads
+	if numThreads == 0 {
+		numThreads = runtime.NumCPU()
+	}
+	sizes := poolSizes(numThreads, opts.PoolSize)
+
+	// Seed every worker from the master seed, in a fixed order
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	masterRand := rand.New(rand.NewSource(seed))
 
 	// Signal we are starting
 	startTime := time.Now()
 	if logVerbose {
 		fmt.Printf("Cracking Cipher Text:\n%s\n\n", ciphertext)
+		fmt.Printf("Seed: %d\n\n", seed)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	globalData := newGlobalData(ciphertext, opts)
+	layout := globalData.layout
 
-	var waitGroup sync.WaitGroup
-	waitGroup.Add(numPools * poolSize)
+	// A seriated ciphertext of unknown period is cracked under every period
+	// it allows at once, a pool to each, pools taking turns at periods when
+	// there are more of them than pools
+	if opts.SearchPeriods {
+		globalData.periods = seriationPeriods(globalData.ciphertext)
+		if len(globalData.periods) == 0 {
+			return nil, fmt.Errorf("Every period leaves a doubled column in the ciphertext, it is not seriated")
+		}
+		if len(globalData.periods) > len(sizes) {
+			sizes = poolSizes(numThreads, max(1, numThreads/len(globalData.periods)))
+		}
 
+		if logVerbose {
+			fmt.Printf("Searching periods %v\n\n", globalData.periods)
+		}
+	}
+
+	// Keep only the crib placements that fit the ciphertext and the pins
+	if opts.Crib != "" {
+		globalData.crib = newCribConstraint(globalData.ciphertext, []byte(opts.Crib), separatorLetter, globalData.pins)
+		if len(globalData.crib.placements) == 0 {
+			return nil, fmt.Errorf("The crib %s does not fit anywhere in the ciphertext", opts.Crib)
+		}
+		if !globalData.crib.solved() {
+			return nil, fmt.Errorf("No grid holding the crib %s was found within the search budget", opts.Crib)
+		}
+
+		if logVerbose {
+			fmt.Printf("Crib %s fits at %d placements\n\n", opts.Crib, len(globalData.crib.placements))
+		}
+	}
+
+	// Few free cells are quicker to try exhaustively than to anneal
+	var solution CrackResult
+	isPlayfair := opts.Cipher == cipher.PLAYFAIR && len(globalData.periods) == 1
+	if free := opts.Pins.FreeCells(); isPlayfair && layout.IsStandard() && len(free) <= opts.ExhaustiveLimit {
+		if logVerbose {
+			fmt.Printf("Trying every key for the %d free cells\n\n", len(free))
+		}
+		solution = exhaustiveCrack(ctx, globalData, free)
+	} else {
+		if opts.DictionaryAttack && isPlayfair {
+			solution, globalData.seeds = dictionaryAttack(ctx, globalData, numThreads)
+		}
+		if !solution.Confirmed {
+			solution = annealingCrack(ctx, globalData, sizes, masterRand)
+		}
+	}
+
+	// Update elapsed time and return
+	solution.Candidates = globalData.leaders.candidates(globalData)
+	solution.Seed = seed
+	solution.ElapsedTime = time.Now().Sub(startTime)
+	return &solution, nil
+}
+
+// newGlobalData sets up the cracking of ciphertext under the period of opts,
+// which must be valid.
+func newGlobalData(ciphertext string, opts CrackOptions) *globalData {
+	layout := opts.layout()
 	globalData := &globalData{
-		solutionChan:    make(chan CrackResult),
-		chanLock:        sync.Mutex{},
-		cancel:          cancel,
+		candidates:      make(chan keyData),
+		leaders:         newLeaderboard(opts.TopN, opts.Cipher),
+		scorer:          score.GetNgramScorerInstance(),
+		periods:         []int{opts.Period},
 		ciphertext:      []byte(ciphertext),
-		excludedLetter:  excludedLetter,
-		separatorLetter: separatorLetter,
+		layout:          layout,
+		excludedLetter:  layout.Excluded(),
+		separatorLetter: opts.SeparatorLetter,
+		opts:            opts,
+	}
+
+	if layout.IsStandard() {
+		globalData.scorer = score.GetFoldedNgramScorer(globalData.excludedLetter, opts.ReplacementLetter)
+	}
+	if len(opts.Pins.FreeCells()) < 25 {
+		globalData.pins = &globalData.opts.Pins
+	}
+	return globalData
+}
+
+// annealingCrack runs a pool of annealing workers for every entry of sizes
+// until the verifier confirms a key, every worker has run out of epochs or
+// ctx is done.
+func annealingCrack(ctx context.Context, globalData *globalData, sizes []int, masterRand *rand.Rand) CrackResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Workers are waited on apart from the pools and the verifier, which run
+	// until ctx is done
+	var waitGroup, workers sync.WaitGroup
+	for _, size := range sizes {
+		workers.Add(size)
 	}
+	waitGroup.Add(len(sizes) + 2)
 
 	// Start each pool
-	for range numPools {
+	pools := make([]*poolData, len(sizes))
+	periods := poolPeriods(globalData.periods, len(sizes))
+	worker := 0
+	for p, poolSize := range sizes {
 		poolData := &poolData{
 			bestScore:   math.Inf(-1),
 			bestLock:    sync.Mutex{},
 			currentKeys: make([]keyData, poolSize),
-			currentLock: sync.Mutex{},
+			epochs:      make([]int, poolSize),
+			reports:     make(chan keyReport),
+			snapshots:   make([]chan []keyData, poolSize),
+			periods:     periods[p],
+			turnEpochs:  turnEpochs(globalData.opts, len(periods[p])),
+			parked:      make([][]keyData, len(periods[p])),
 			global:      globalData,
 		}
 
-		// Get a random starting keys
+		// Start from the best keyword grids, or random keys holding the crib
+		// and pins, under the first period of the pool
+		period := poolData.periods[0]
+		rngs := make([]*rand.Rand, poolSize)
 		for i := 0; i < poolSize; i++ {
-			poolData.currentKeys[i].key = cipher.GenerateRandomKey(globalData.excludedLetter)
-			plaintext := cipher.PlayfairDecrypt(globalData.ciphertext, poolData.currentKeys[i].key, globalData.excludedLetter)
-			poolData.currentKeys[i].score = score.ScoreTextFast(plaintext, globalData.excludedLetter)
+			rngs[i] = rand.New(rand.NewSource(masterRand.Int63()))
+			poolData.snapshots[i] = make(chan []keyData, 1)
+			if len(globalData.seeds) > 0 {
+				poolData.currentKeys[i].keys = globalData.seeds[worker%len(globalData.seeds)].keys
+			} else if globalData.crib != nil {
+				poolData.currentKeys[i].keys = playfairKeys(globalData.crib.randomKey(rngs[i], globalData.excludedLetter), period)
+			} else {
+				poolData.currentKeys[i].keys = globalData.randomKeys(rngs[i], period)
+			}
+			poolData.currentKeys[i].score = globalData.scoreKey(poolData.currentKeys[i].keys)
+
+			globalData.leaders.offer(poolData.currentKeys[i])
+			if poolData.currentKeys[i].score > poolData.bestScore {
+				poolData.bestScore = poolData.currentKeys[i].score
+				poolData.bestKey = poolData.currentKeys[i].keys
+			}
+			worker++
 		}
+		pools[p] = poolData
 
+		go runPool(ctx, &waitGroup, poolData)
 		for i, keyData := range poolData.currentKeys {
 			go processWorker(
 				ctx,
-				logVerbose,
-				&waitGroup,
+				globalData.opts.LogVerbose,
+				&workers,
 				poolData,
 				i,
-				keyData.key,
+				rngs[i],
+				keyData,
 			)
 		}
 	}
 
+	// Check candidates as workers submit them
+	solutions := make(chan CrackResult, 1)
+	go runVerifier(ctx, &waitGroup, globalData, checkForSolution, solutions)
+
+	// Notice when every worker has run out of epochs
+	exhausted := make(chan struct{})
+	go func() {
+		defer waitGroup.Done()
+		workers.Wait()
+		close(exhausted)
+	}()
+
 	var solution CrackResult
 	select {
-	case solution = <-globalData.solutionChan:
-		// A worker found the key, cancel other workers
-		cancel()
+	case solution = <-solutions:
+		// The verifier confirmed a key
+	case <-exhausted:
+		// Every worker ran its epochs
 	case <-ctx.Done():
 		// Context canceled externally
 	}
 
-	// Wait for all goroutines to finish
+	// Stop the other workers and wait for all goroutines to finish
+	cancel()
 	waitGroup.Wait()
 
-	// Update elapsed time and return
-	solution.ElapsedTime = time.Now().Sub(startTime)
-	return &solution
+	// Fall back on the best key seen if nothing was confirmed
+	if !solution.Confirmed {
+		solution = bestEffortResult(globalData, pools)
+	}
+	return solution
+}
+
+// randomKeys returns random grids for every grid of the cipher under period,
+// keeping any pinned letters in their cells.
+func (globalData *globalData) randomKeys(rng *rand.Rand, period int) keySet {
+	keys := keySet{period: period}
+	for i := 0; i < globalData.opts.Cipher.Grids(); i++ {
+		if globalData.layout.IsStandard() {
+			keys.grids[i] = cipher.RandomKey(rng, globalData.excludedLetter, globalData.pins)
+		} else {
+			keys.grids[i] = globalData.layout.RandomKey(rng)
+		}
+	}
+	return keys
+}
+
+// decrypt decrypts the ciphertext under keys with the cipher and rules being
+// cracked.
+func (globalData *globalData) decrypt(keys keySet) []byte {
+	return globalData.opts.Cipher.Decrypt(globalData.ciphertext, keys.grids, keys.period, globalData.opts.Rules)
+}
+
+// scoreKey is the fast score of the decryption under keys.
+func (globalData *globalData) scoreKey(keys keySet) float64 {
+	return globalData.scorer.Score(globalData.decrypt(keys), globalData.separatorLetter)
+}
+
+// scoreEnglish runs the slow English check on plaintext, looking words up
+// folded the same way as the fast score when the grid leaves a letter out.
+func (globalData *globalData) scoreEnglish(plaintext []byte) (float64, []string) {
+	if globalData.layout.IsStandard() {
+		return score.ScoreFoldedTextSlow(plaintext, globalData.separatorLetter, 1.5, globalData.excludedLetter, globalData.opts.ReplacementLetter)
+	}
+	return score.ScoreTextSlow(plaintext, globalData.separatorLetter, 1.5)
+}
+
+// holdsCrib reports whether keys encrypt the crib at some placement, always
+// true without a crib.
+func (globalData *globalData) holdsCrib(keys keySet) bool {
+	return globalData.crib == nil || globalData.crib.fit(keys.grids[0]) >= 0
+}
+
+// poolSizes splits numThreads workers into pools of poolSize, the last pool
+// taking whatever is left over. There is always at least one pool.
+func poolSizes(numThreads int, poolSize int) []int {
+	if numThreads < 1 {
+		numThreads = 1
+	}
+
+	var sizes []int
+	for numThreads > 0 {
+		size := min(poolSize, numThreads)
+		sizes = append(sizes, size)
+		numThreads -= size
+	}
+	return sizes
 }
 
 func processWorker(
@@ -125,17 +364,18 @@ func processWorker(
 	waitGroup *sync.WaitGroup,
 	poolData *poolData,
 	pid int,
-	initialKey [25]byte,
+	rng *rand.Rand,
+	initialKey keyData,
 ) {
 	defer waitGroup.Done()
 
 	// initialize search
-	localBest := poolData.bestScore
-	localBestKey := initialKey
+	current := initialKey
+	localBest := initialKey.score
+	localBestKey := initialKey.keys
 	localSinceBest := 0
 
-	temperature := 50.0
-	coolingRate := 0.01
+	opts := poolData.global.opts
 	epoch := 0
 
 	for {
@@ -154,13 +394,14 @@ func processWorker(
 			logVerbose,
 			poolData,
 			pid,
-			poolData.currentKeys[pid].key,
-			temperature,
-			0.1,
-			coolingRate,
-			1024,
-			50000,
-			5,
+			rng,
+			&current,
+			opts.InitialTemp,
+			opts.FloorTemp,
+			opts.CoolingRate,
+			opts.TriesPerEpoch,
+			opts.TriesBeforeStagnation,
+			opts.GeneticTempMultiplier,
 		)
 
 		// compare to local best
@@ -172,53 +413,144 @@ func processWorker(
 			localSinceBest++
 		}
 
-		// Check for solution
-		if -3000 < localBest && checkForSolution(ctx, poolData.global, localBestKey) {
+		// Hand our best key over to be checked
+		if opts.ScoreGate < localBest && !submitCandidate(ctx, poolData.global, keyData{score: localBest, keys: localBestKey}) {
+			return
+		}
+		if poolData.exhausted(pid) {
 			return
 		}
 	}
 }
 
-func checkForSolution(
-	ctx context.Context,
-	globalData *globalData,
-	key [25]byte,
-) bool {
-	globalData.chanLock.Lock()
-	defer globalData.chanLock.Unlock()
+// runPool collects one report from every worker in the pool and then hands
+// each of them a copy of all the reported keys, until ctx is done. The pool
+// moves on to its next period between the two every turnEpochs rounds.
+func runPool(ctx context.Context, waitGroup *sync.WaitGroup, poolData *poolData) {
+	defer waitGroup.Done()
+
+	for round := 1; ; round++ {
+		for range len(poolData.currentKeys) {
+			select {
+			case <-ctx.Done():
+				return
+			case report := <-poolData.reports:
+				poolData.currentKeys[report.pid] = report.keyData
+			}
+		}
+
+		if len(poolData.periods) > 1 && round%poolData.turnEpochs == 0 {
+			poolData.nextTurn()
+		}
+
+		for _, snapshot := range poolData.snapshots {
+			select {
+			case <-ctx.Done():
+				return
+			case snapshot <- slices.Clone(poolData.currentKeys):
+			}
+		}
+	}
+}
+
+// exchangeKeys reports the current key of worker pid to its pool and waits
+// for the keys of the whole pool, returning false if ctx is done first or the
+// worker has run out of epochs with this exchange.
+func exchangeKeys(ctx context.Context, poolData *poolData, pid int, current keyData) ([]keyData, bool) {
+	select {
+	case <-ctx.Done():
+		return nil, false
+	case poolData.reports <- keyReport{pid: pid, keyData: current}:
+	}
 
-	// Check if other already sent solution
 	select {
 	case <-ctx.Done():
-		return false
-	default:
+		return nil, false
+	case snapshot := <-poolData.snapshots[pid]:
+		poolData.epochs[pid]++
+		return snapshot, !poolData.exhausted(pid)
+	}
+}
+
+// exhausted reports whether worker pid has run CrackOptions.Epochs epochs,
+// counted by the keys it exchanged with its pool. Only worker pid touches
+// its count.
+func (poolData *poolData) exhausted(pid int) bool {
+	epochs := poolData.global.opts.Epochs
+	return epochs > 0 && poolData.epochs[pid] >= epochs
+}
+
+// nextTurn parks the current keys of the pool under its period and moves on
+// to the next period, picking its parked keys back up. A period visited for
+// the first time starts from the current grids.
+func (poolData *poolData) nextTurn() {
+	poolData.parked[poolData.turn] = slices.Clone(poolData.currentKeys)
+	poolData.turn = (poolData.turn + 1) % len(poolData.periods)
+
+	if parked := poolData.parked[poolData.turn]; parked != nil {
+		copy(poolData.currentKeys, parked)
+		return
+	}
+	for i := range poolData.currentKeys {
+		poolData.currentKeys[i].keys.period = poolData.periods[poolData.turn]
+		poolData.currentKeys[i].score = poolData.global.scoreKey(poolData.currentKeys[i].keys)
 	}
+}
 
-	// Check for solution
-	solution := CrackResult{}
-	plaintext := cipher.PlayfairDecrypt(globalData.ciphertext, key, globalData.excludedLetter)
-	solution.PercentEnglish, solution.SegmentedText = score.ScoreTextSlow(plaintext, globalData.separatorLetter, 1.5)
+// poolPeriods deals periods out to pools in turn, so that every period is
+// searched by some pool. With fewer periods than pools they are dealt again.
+func poolPeriods(periods []int, pools int) [][]int {
+	dealt := make([][]int, pools)
+	for p := range dealt {
+		for i := p % len(periods); i < len(periods); i += pools {
+			dealt[p] = append(dealt[p], periods[i])
+		}
+	}
+	return dealt
+}
 
-	// We did not find solution
-	if solution.PercentEnglish < THRESHHOLD_ENGLISH {
-		return false
+// turnEpochs returns the epochs a pool searching periods periods spends on
+// each in turn: one annealing run from InitialTemp down to FloorTemp, cut
+// short so that every period gets a turn when Epochs is capped.
+func turnEpochs(opts CrackOptions, periods int) int {
+	epochs := 0
+	for temp := opts.InitialTemp; temp >= opts.FloorTemp; temp *= (1 - opts.CoolingRate) {
+		epochs++
+	}
+	if opts.Epochs > 0 {
+		epochs = min(epochs, opts.Epochs/periods)
 	}
+	return max(1, epochs)
+}
 
-	// Add result data
-	var keyBuilder strings.Builder
-	for _, b := range key {
-		keyBuilder.WriteByte(b)
+func bestEffortResult(globalData *globalData, pools []*poolData) CrackResult {
+	bestScore := math.Inf(-1)
+	var bestKey keySet
+	for _, poolData := range pools {
+		poolData.bestLock.Lock()
+		if poolData.bestScore > bestScore {
+			bestScore = poolData.bestScore
+			bestKey = poolData.bestKey
+		}
+		poolData.bestLock.Unlock()
 	}
-	solution.Key = keyBuilder.String()
-	solution.Plaintext = string(plaintext)
 
-	select {
-	case <-ctx.Done():
-		// Context canceled, do not attempt to send
-		return true
-	case globalData.solutionChan <- solution:
-		// Successfully sent the solution, cancel others
-		globalData.cancel()
-		return true
+	// No pool got far enough to score a key
+	if math.IsInf(bestScore, -1) {
+		return CrackResult{Score: bestScore}
 	}
+
+	return unconfirmedResult(globalData, keyData{score: bestScore, keys: bestKey})
+}
+
+// unconfirmedResult describes the decryption under best without it having
+// passed the English check.
+func unconfirmedResult(globalData *globalData, best keyData) CrackResult {
+	result := CrackResult{Score: best.score}
+	plaintext := globalData.decrypt(best.keys)
+	result.PercentEnglish, result.SegmentedText = globalData.scoreEnglish(plaintext)
+	result.Key = best.keys.String()
+	result.Period = best.keys.period
+	result.Plaintext = string(plaintext)
+	return result
 }
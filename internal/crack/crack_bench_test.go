@@ -16,8 +16,6 @@ func stringTo25Byte(s string) [25]byte {
 }
 
 // func BenchmarkPlayfairCrack(b *testing.B) {
-// 	rand.Seed(42)
-
 // 	// Load static scoring components
 // 	score.GetNgramScorerInstance()
 // 	score.GetSegmentorInstance()
@@ -26,17 +24,19 @@ func stringTo25Byte(s string) [25]byte {
 // 	b.ResetTimer()
 // 	for i := 0; i < b.N; i++ {
 // 		for i, ct := range testdata.BenchCiphertexts {
-// 			result := PlayfairCrack(ct, 'J', 'X', false)
-// 			pt := testdata.BenchPlaintexts[i]
-// 			if result.Plaintext != pt {
-// 				b.Fatalf("Failed cracking:\ngot %v,\nexpected %q", result.Plaintext, pt)
+// 			opts := DefaultCrackOptions()
+// 			opts.Seed = 42
+// 			result, _ := PlayfairCrack(context.Background(), ct, opts)
+// 			correct := testKey(testdata.BenchKeys[i])
+// 			if distance := correct.Distance(testKey(result.Key)); distance > 0 {
+// 				b.Logf("Failed cracking %d, %d swaps from correct", i, distance)
 // 			}
 // 		}
 // 	}
 // }
 
 func BenchmarkInnerLoop(b *testing.B) {
-	rand.Seed(42)
+	rng := rand.New(rand.NewSource(42))
 
 	// Load static scoring components
 	score.GetNgramScorerInstance()
@@ -59,7 +59,7 @@ func BenchmarkInnerLoop(b *testing.B) {
 			// Ignore return
 		}
 
-		candidateKey := cipher.PermuteKey(currentKey, 'J')
+		candidateKey := cipher.PermuteKey(rng, currentKey, 'J')
 		candidatePlaintext := cipher.PlayfairDecrypt(ciphertext, candidateKey, 'J')
 		candidateScore := score.ScoreTextFast(candidatePlaintext, 'X')
 
@@ -68,7 +68,7 @@ func BenchmarkInnerLoop(b *testing.B) {
 		deltaRatio := delta / curTemp
 		acceptanceRate := math.Exp(deltaRatio)
 
-		if rand.Float64() < acceptanceRate {
+		if rng.Float64() < acceptanceRate {
 			currentScore = candidateScore
 			currentKey = candidateKey
 		}
@@ -0,0 +1,641 @@
+package crack
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"playfaircrack/internal/cipher"
+	"playfaircrack/internal/crack/testdata"
+	"playfaircrack/internal/score"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testKey indexes a 25 letter grid without J.
+func testKey(grid string) cipher.Key {
+	return cipher.NewKey([25]byte([]byte(grid)), 'J')
+}
+
+func TestPoolSizes(t *testing.T) {
+	tests := []struct {
+		numThreads int
+		poolSize   int
+		sizes      []int
+	}{
+		{numThreads: 1, poolSize: 4, sizes: []int{1}},
+		{numThreads: 3, poolSize: 4, sizes: []int{3}},
+		{numThreads: 8, poolSize: 4, sizes: []int{4, 4}},
+		{numThreads: 6, poolSize: 4, sizes: []int{4, 2}},
+		{numThreads: 5, poolSize: 1, sizes: []int{1, 1, 1, 1, 1}},
+		{numThreads: 0, poolSize: 4, sizes: []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d/%d", tt.numThreads, tt.poolSize), func(t *testing.T) {
+			assert.Equal(t, tt.sizes, poolSizes(tt.numThreads, tt.poolSize))
+		})
+	}
+}
+
+func TestPoolPeriods(t *testing.T) {
+	// Every period goes to some pool, dealt again when there are too few
+	assert.Equal(t, [][]int{{2, 5, 8}, {3, 6}, {4, 7}}, poolPeriods([]int{2, 3, 4, 5, 6, 7, 8}, 3))
+	assert.Equal(t, [][]int{{2}, {3}, {2}}, poolPeriods([]int{2, 3}, 3))
+
+	// A turn is one annealing run, shared out when epochs are capped
+	opts := DefaultCrackOptions()
+	opts.InitialTemp, opts.FloorTemp, opts.CoolingRate = 8, 1, 0.5
+	assert.Equal(t, 4, turnEpochs(opts, 3))
+	opts.Epochs = 6
+	assert.Equal(t, 2, turnEpochs(opts, 3))
+	opts.Epochs = 2
+	assert.Equal(t, 1, turnEpochs(opts, 3))
+
+	// Turns park the keys of a period and pick them back up
+	ciphertext := cipher.SeriatedPlayfairEncrypt([]byte(testdata.BenchPlaintexts[4]), testKey(testdata.BenchKeys[4]), 7)
+	globalData := newGlobalData(string(ciphertext), DefaultCrackOptions())
+	keys := playfairKeys(testKey(testdata.BenchKeys[1]), 3)
+	poolData := &poolData{
+		currentKeys: []keyData{{score: globalData.scoreKey(keys), keys: keys}},
+		periods:     []int{3, 7},
+		parked:      make([][]keyData, 2),
+		global:      globalData,
+	}
+	poolData.nextTurn()
+	assert.Equal(t, 7, poolData.currentKeys[0].keys.period)
+	assert.Equal(t, globalData.scoreKey(poolData.currentKeys[0].keys), poolData.currentKeys[0].score)
+
+	poolData.currentKeys[0] = keyData{score: 1, keys: playfairKeys(testKey(testdata.BenchKeys[2]), 7)}
+	poolData.nextTurn()
+	assert.Equal(t, []keyData{{score: globalData.scoreKey(keys), keys: keys}}, poolData.currentKeys)
+	poolData.nextTurn()
+	assert.Equal(t, []keyData{{score: 1, keys: playfairKeys(testKey(testdata.BenchKeys[2]), 7)}}, poolData.currentKeys)
+}
+
+func TestValidate(t *testing.T) {
+	alphanumeric, err := cipher.ParseLayout("6x6", "", 'J')
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		modify func(opts *CrackOptions)
+	}{
+		{name: "negative period", modify: func(opts *CrackOptions) { opts.Period = -1 }},
+		{name: "period search on doubles", modify: func(opts *CrackOptions) { opts.SearchPeriods, opts.Cipher = true, cipher.TWO_SQUARE }},
+		{name: "seriated crib", modify: func(opts *CrackOptions) { opts.Period, opts.Crib = 5, "THE" }},
+		{name: "rules off Playfair", modify: func(opts *CrackOptions) { opts.Rules, opts.Cipher = cipher.LEFT_UP_RULES, cipher.FOUR_SQUARE }},
+		{name: "crib under other rules", modify: func(opts *CrackOptions) { opts.Rules, opts.Crib = cipher.LEFT_UP_RULES, "THE" }},
+		{name: "crib off Playfair", modify: func(opts *CrackOptions) { opts.Cipher, opts.Crib = cipher.TWO_SQUARE, "THE" }},
+		{name: "pins off Playfair", modify: func(opts *CrackOptions) { opts.Cipher, opts.Pins[0] = cipher.TWO_SQUARE, 'P' }},
+		{name: "crib off 5x5", modify: func(opts *CrackOptions) { opts.Layout, opts.Crib = alphanumeric, "THE" }},
+		{name: "pins off 5x5", modify: func(opts *CrackOptions) { opts.Layout, opts.Pins[0] = alphanumeric, 'P' }},
+		{name: "excluded replacement", modify: func(opts *CrackOptions) { opts.ReplacementLetter = 'J' }},
+		{name: "excluded separator", modify: func(opts *CrackOptions) { opts.SeparatorLetter = 'J' }},
+		{name: "excluded crib letter", modify: func(opts *CrackOptions) { opts.Crib = "JAM" }},
+		{name: "one letter crib", modify: func(opts *CrackOptions) { opts.Crib = "A" }},
+		{name: "letter pinned twice", modify: func(opts *CrackOptions) { opts.Pins[0], opts.Pins[1] = 'P', 'P' }},
+		{name: "exhaustive limit", modify: func(opts *CrackOptions) { opts.ExhaustiveLimit = 11 }},
+		{name: "zero floor", modify: func(opts *CrackOptions) { opts.FloorTemp = 0 }},
+		{name: "floor above temp", modify: func(opts *CrackOptions) { opts.InitialTemp, opts.FloorTemp = 1, 2 }},
+		{name: "no cooling", modify: func(opts *CrackOptions) { opts.CoolingRate = 0 }},
+		{name: "full cooling", modify: func(opts *CrackOptions) { opts.CoolingRate = 1 }},
+		{name: "no tries", modify: func(opts *CrackOptions) { opts.TriesPerEpoch = 0 }},
+		{name: "negative epochs", modify: func(opts *CrackOptions) { opts.Epochs = -1 }},
+		{name: "no stagnation tries", modify: func(opts *CrackOptions) { opts.TriesBeforeStagnation = 0 }},
+		{name: "non-negative gate", modify: func(opts *CrackOptions) { opts.ScoreGate = 0 }},
+		{name: "zero multiplier", modify: func(opts *CrackOptions) { opts.GeneticTempMultiplier = 0 }},
+		{name: "negative top", modify: func(opts *CrackOptions) { opts.TopN = -1 }},
+		{name: "negative threads", modify: func(opts *CrackOptions) { opts.Threads = -1 }},
+		{name: "empty pools", modify: func(opts *CrackOptions) { opts.PoolSize = 0 }},
+	}
+
+	assert.NoError(t, DefaultCrackOptions().Validate())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultCrackOptions()
+			tt.modify(&opts)
+			assert.Error(t, opts.Validate())
+		})
+	}
+}
+
+// quickCrackOptions are the options of a short crack run of a fixed number
+// of epochs, submitting every key.
+func quickCrackOptions() CrackOptions {
+	opts := DefaultCrackOptions()
+	opts.DictionaryAttack = false
+	opts.Seed = 42
+	opts.Threads = 2
+	opts.TriesPerEpoch = 64
+	opts.TriesBeforeStagnation = 500
+	opts.Epochs = 30
+	opts.ScoreGate = -1e6
+	return opts
+}
+
+// assertBeatsRandom checks that keys, the true keys of ciphertext, and the
+// key result found both score above the best of a hundred random keys.
+func assertBeatsRandom(t *testing.T, ciphertext []byte, opts CrackOptions, keys keySet, result *CrackResult) {
+	t.Helper()
+
+	globalData := newGlobalData(string(ciphertext), opts)
+	rng := rand.New(rand.NewSource(1))
+	random := math.Inf(-1)
+	for range 100 {
+		random = max(random, globalData.scoreKey(globalData.randomKeys(rng, keys.period)))
+	}
+
+	assert.Greater(t, globalData.scoreKey(keys), random)
+	assert.Greater(t, result.Score, random)
+}
+
+// TestPlayfairCrackShortRun runs every stage of a crack for a moment, run it
+// with -race to check the pools and candidate checks for data races.
+func TestPlayfairCrackShortRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping crack run in short mode")
+	}
+
+	opts := DefaultCrackOptions()
+	opts.Seed = 42
+	opts.Threads = 4
+	opts.PoolSize = 2
+	opts.TriesPerEpoch = 64
+	opts.TriesBeforeStagnation = 500
+	opts.ScoreGate = -1e6
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	result, err := PlayfairCrack(ctx, testdata.BenchCiphertexts[0], opts)
+	assert.NoError(t, err)
+	assert.Len(t, result.Key, 25)
+	assert.Len(t, result.Plaintext, len(testdata.BenchCiphertexts[0]))
+	assert.Equal(t, int64(42), result.Seed)
+
+	correct := testKey(testdata.BenchKeys[0])
+	t.Logf("%d swaps from correct", correct.Distance(testKey(result.Key)))
+}
+
+func TestPlayfairCrackReproducible(t *testing.T) {
+	opts := DefaultCrackOptions()
+	opts.DictionaryAttack = false
+	opts.Seed = 7
+	opts.Threads = 4
+	opts.PoolSize = 2
+	opts.TriesPerEpoch = 64
+	opts.Epochs = 20
+	// Submitting nothing keeps the verifier out of it
+	opts.ScoreGate = -1
+
+	var results []*CrackResult
+	for range 2 {
+		result, err := PlayfairCrack(context.Background(), testdata.BenchCiphertexts[0], opts)
+		assert.NoError(t, err)
+		assert.False(t, result.Confirmed)
+		results = append(results, result)
+	}
+	assert.Len(t, results[0].Key, 25)
+	assert.Equal(t, results[0].Key, results[1].Key)
+	assert.Equal(t, results[0].Score, results[1].Score)
+}
+
+func TestPlayfairCrackAlphanumeric(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping crack run in short mode")
+	}
+
+	layout, err := cipher.ParseLayout("6x6", "", 'J')
+	assert.NoError(t, err)
+	key := layout.KeywordKey("harbour 1984")
+	ciphertext := key.Encrypt([]byte(testdata.BenchPlaintexts[2]))
+
+	opts := quickCrackOptions()
+	opts.Layout = layout
+	opts.TopN = 3
+
+	result, err := PlayfairCrack(context.Background(), string(ciphertext), opts)
+	assert.NoError(t, err)
+	assert.Len(t, result.Plaintext, len(ciphertext))
+	cracked, err := layout.ParseKey(result.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, cracked.Rows())
+	assert.NotEmpty(t, result.Candidates)
+	assert.LessOrEqual(t, len(result.Candidates), 3)
+
+	// The true key scores above random keys, which the crack improves on
+	assertBeatsRandom(t, ciphertext, opts, playfairKeys(key, 0), result)
+}
+
+func TestPlayfairCrackTwoGrids(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping crack run in short mode")
+	}
+
+	keys := [2]cipher.Key{testKey(testdata.BenchKeys[1]), testKey(testdata.BenchKeys[2])}
+	for _, cipherType := range []cipher.Type{cipher.TWO_SQUARE, cipher.FOUR_SQUARE, cipher.DOUBLE_PLAYFAIR} {
+		t.Run(cipherType.String(), func(t *testing.T) {
+			ciphertext := cipherType.Encrypt([]byte(testdata.BenchPlaintexts[1]), keys, 0, cipher.STANDARD_RULES)
+
+			opts := quickCrackOptions()
+			opts.Cipher = cipherType
+
+			result, err := PlayfairCrack(context.Background(), string(ciphertext), opts)
+			assert.NoError(t, err)
+			assert.Len(t, result.Plaintext, len(ciphertext))
+			grids := strings.Fields(result.Key)
+			assert.Len(t, grids, 2)
+			assert.Len(t, grids[1], 25)
+			assertBeatsRandom(t, ciphertext, opts, keySet{grids: keys}, result)
+		})
+	}
+}
+
+func TestPlayfairCrackRules(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping crack run in short mode")
+	}
+
+	key := testKey(testdata.BenchKeys[3])
+	for _, rules := range []cipher.Rules{cipher.LEFT_UP_RULES, cipher.OPPOSITE_CORNER_RULES, cipher.SAME_ROW_UNCHANGED_RULES} {
+		t.Run(rules.String(), func(t *testing.T) {
+			ciphertext := cipher.PLAYFAIR.Encrypt([]byte(testdata.BenchPlaintexts[3]), [2]cipher.Key{key}, 0, rules)
+
+			opts := quickCrackOptions()
+			opts.Rules = rules
+
+			result, err := PlayfairCrack(context.Background(), string(ciphertext), opts)
+			assert.NoError(t, err)
+			cracked := testKey(result.Key)
+			assert.Equal(t, result.Plaintext, string(cracked.DecryptWith(ciphertext, rules)))
+			assertBeatsRandom(t, ciphertext, opts, playfairKeys(key, 0), result)
+		})
+	}
+}
+
+func TestPlayfairCrackSeriated(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping crack run in short mode")
+	}
+
+	period := 7
+	plaintext := cipher.SeparateColumns([]byte(testdata.BenchPlaintexts[4]), 'X', period)
+	key := testKey(testdata.BenchKeys[4])
+	ciphertext := cipher.SeriatedPlayfairEncrypt(plaintext, key, period)
+	assert.Contains(t, seriationPeriods(ciphertext), period)
+
+	opts := quickCrackOptions()
+	opts.SearchPeriods = true
+	opts.Threads = 4
+	opts.PoolSize = 1
+
+	result, err := PlayfairCrack(context.Background(), string(ciphertext), opts)
+	assert.NoError(t, err)
+	assert.Len(t, result.Key, 25)
+	assert.Contains(t, seriationPeriods(ciphertext), result.Period)
+	assertBeatsRandom(t, ciphertext, opts, playfairKeys(key, period), result)
+
+	// A single pool takes turns at every period of a short ciphertext
+	ciphertext = ciphertext[:42]
+	opts.Threads = 1
+	opts.TopN = 1000
+	result, err = PlayfairCrack(context.Background(), string(ciphertext), opts)
+	assert.NoError(t, err)
+	searched := map[int]bool{}
+	for _, candidate := range result.Candidates {
+		searched[candidate.Period] = true
+	}
+	assert.Greater(t, len(seriationPeriods(ciphertext)), 1)
+	for _, period := range seriationPeriods(ciphertext) {
+		assert.True(t, searched[period], "period %d", period)
+	}
+}
+
+func TestKeySetPermute(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	keys := keySet{grids: [2]cipher.Key{testKey(testdata.BenchKeys[1]), testKey(testdata.BenchKeys[2])}}
+
+	// Every change touches one grid only
+	for range 100 {
+		permuted := keys.permute(rng, 2, nil)
+		assert.True(t, permuted.grids[0] == keys.grids[0] || permuted.grids[1] == keys.grids[1])
+	}
+
+	// Playfair grids are equivalent under rotation
+	first, second := keys.grids[0], keys.grids[1]
+	rotated := playfairKeys(first.Rotate(1, 2), 0)
+	assert.True(t, rotated.equivalent(playfairKeys(first, 0), cipher.PLAYFAIR))
+	assert.False(t, rotated.equivalent(playfairKeys(first, 7), cipher.PLAYFAIR))
+
+	// Two grids side by side move their rows together and their columns
+	// apart, one above the other the other way around, and decrypt the same
+	plaintext := []byte(testdata.BenchPlaintexts[1])
+	tests := []struct {
+		cipherType cipher.Type
+		together   keySet
+		apart      keySet
+	}{
+		{
+			cipherType: cipher.DOUBLE_PLAYFAIR,
+			together:   keySet{grids: [2]cipher.Key{first.Rotate(1, 2), second.Rotate(1, 4)}},
+			apart:      keySet{grids: [2]cipher.Key{first.Rotate(1, 2), second.Rotate(3, 4)}},
+		},
+		{
+			cipherType: cipher.TWO_SQUARE,
+			together:   keySet{grids: [2]cipher.Key{first.Rotate(1, 2), second.Rotate(1, 4)}},
+			apart:      keySet{grids: [2]cipher.Key{first.Rotate(1, 2), second}},
+		},
+		{
+			cipherType: cipher.TWO_SQUARE_VERTICAL,
+			together:   keySet{grids: [2]cipher.Key{first.Rotate(2, 1), second.Rotate(4, 1)}},
+			apart:      keySet{grids: [2]cipher.Key{first.Rotate(2, 1), second.Rotate(4, 3)}},
+		},
+		{
+			cipherType: cipher.FOUR_SQUARE,
+			apart:      keySet{grids: [2]cipher.Key{first.Rotate(1, 2), second.Rotate(1, 4)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cipherType.String(), func(t *testing.T) {
+			ciphertext := tt.cipherType.Encrypt(plaintext, keys.grids, 0, cipher.STANDARD_RULES)
+			if tt.together != (keySet{}) {
+				assert.True(t, tt.together.equivalent(keys, tt.cipherType))
+				assert.Equal(t, ciphertext, tt.cipherType.Encrypt(plaintext, tt.together.grids, 0, cipher.STANDARD_RULES))
+			}
+			assert.False(t, tt.apart.equivalent(keys, tt.cipherType))
+			assert.NotEqual(t, ciphertext, tt.cipherType.Encrypt(plaintext, tt.apart.grids, 0, cipher.STANDARD_RULES))
+		})
+	}
+}
+
+func TestInsertByScore(t *testing.T) {
+	var queue []keyData
+	for _, score := range []float64{-300, -100, -500, -200} {
+		queue = insertByScore(queue, keyData{score: score})
+	}
+
+	var scores []float64
+	for _, keyData := range queue {
+		scores = append(scores, keyData.score)
+	}
+	assert.Equal(t, []float64{-500, -300, -200, -100}, scores)
+}
+
+func TestVerifier(t *testing.T) {
+	rejected := playfairKeys(testKey(testdata.BenchKeys[1]), 0)
+	confirmed := playfairKeys(testKey(testdata.BenchKeys[2]), 0)
+
+	// Every key but confirmed is rejected
+	checked := make(chan keySet, 10)
+	check := func(_ *globalData, keys keySet) (CrackResult, bool) {
+		checked <- keys
+		return CrackResult{Key: keys.String()}, keys == confirmed
+	}
+	globalData := &globalData{candidates: make(chan keyData), opts: DefaultCrackOptions()}
+
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	solutions := make(chan CrackResult, 1)
+	ctx := context.Background()
+	go runVerifier(ctx, &waitGroup, globalData, check, solutions)
+
+	// A rotation of a queued key is dropped, and the rejection of the first
+	// key frees the checker for the next
+	assert.True(t, submitCandidate(ctx, globalData, keyData{score: -100, keys: rejected}))
+	rotated := playfairKeys(rejected.grids[0].Rotate(2, 1), 0)
+	assert.True(t, submitCandidate(ctx, globalData, keyData{score: -100, keys: rotated}))
+	assert.True(t, submitCandidate(ctx, globalData, keyData{score: -200, keys: confirmed}))
+
+	solution := <-solutions
+	waitGroup.Wait()
+	close(checked)
+	assert.Equal(t, confirmed.String(), solution.Key)
+
+	var order []keySet
+	for keys := range checked {
+		order = append(order, keys)
+	}
+	assert.Equal(t, []keySet{rejected, confirmed}, order)
+}
+
+func TestVerifierWaitsForChecker(t *testing.T) {
+	// The check is still running when the verifier is stopped
+	started := make(chan struct{})
+	var finished atomic.Bool
+	check := func(_ *globalData, keys keySet) (CrackResult, bool) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+		return CrackResult{}, false
+	}
+	globalData := &globalData{candidates: make(chan keyData), opts: DefaultCrackOptions()}
+
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	go runVerifier(ctx, &waitGroup, globalData, check, make(chan CrackResult, 1))
+
+	assert.True(t, submitCandidate(ctx, globalData, keyData{keys: playfairKeys(testKey(testdata.BenchKeys[1]), 0)}))
+	<-started
+	cancel()
+	waitGroup.Wait()
+	assert.True(t, finished.Load())
+}
+
+func TestLeaderboard(t *testing.T) {
+	board := newLeaderboard(3, cipher.PLAYFAIR)
+	for i, score := range []float64{-300, -100, -500, -200, -100, -400} {
+		keyword := string(rune('A' + i))
+		if score == -100 {
+			// Offer the same key twice
+			keyword = "Z"
+		}
+		key := cipher.NewKey(cipher.KeywordKey(keyword, 'J'), 'J')
+		board.offer(keyData{score: score, keys: playfairKeys(key, 0)})
+	}
+
+	var scores []float64
+	for _, keyData := range board.keys {
+		scores = append(scores, keyData.score)
+	}
+	assert.Equal(t, []float64{-100, -200, -300}, scores)
+
+	// An empty board takes nothing
+	empty := newLeaderboard(0, cipher.PLAYFAIR)
+	empty.offer(keyData{score: -100})
+	assert.Empty(t, empty.keys)
+}
+
+func TestCribConstraint(t *testing.T) {
+	ciphertext := []byte(testdata.BenchCiphertexts[16])
+	plaintext := testdata.BenchPlaintexts[16]
+	key := testKey("RSBQLVECTIAWPNGKFYOZHXDMU")
+
+	constraint := newCribConstraint(ciphertext, []byte("SUNSET"), 'X', nil)
+
+	// The crib starts at index 9, halfway through the digraph at offset 8, so
+	// the digraphs following its first letter line up from offset 10
+	assert.Equal(t, 9, strings.Index(plaintext, "SUNSET"))
+	found := false
+	for _, placement := range constraint.placements {
+		if placement.offset == 10 && len(placement.relations) == 2 {
+			found = true
+		}
+	}
+	assert.True(t, found)
+	assert.Less(t, len(constraint.placements), len(ciphertext))
+	assert.GreaterOrEqual(t, constraint.fit(key), 0)
+	assert.True(t, constraint.solved())
+
+	// Seeded keys keep the crib and hold every letter once
+	rng := rand.New(rand.NewSource(42))
+	for range 10 {
+		seededKey := constraint.randomKey(rng, 'J')
+		letters := make(map[byte]bool)
+		for _, l := range seededKey.Cells() {
+			letters[l] = true
+		}
+		assert.Len(t, letters, 25)
+		assert.NotContains(t, letters, byte('J'))
+		assert.GreaterOrEqual(t, constraint.fit(seededKey), 0)
+	}
+
+	// Pins are kept by the grids of the placements, and rule out those that
+	// need their cells
+	var pins cipher.PinnedCells
+	copy(pins[:5], key.Cells()[:5])
+	pinned := newCribConstraint(ciphertext, []byte("SUNSET"), 'X', &pins)
+	assert.NotEmpty(t, pinned.placements)
+	assert.LessOrEqual(t, len(pinned.placements), len(constraint.placements))
+	for range 10 {
+		seededKey := pinned.randomKey(rng, 'J')
+		assert.True(t, pinsHold(seededKey, &pins))
+		assert.GreaterOrEqual(t, pinned.fit(seededKey), 0)
+	}
+}
+
+func TestPlayfairCrackCrib(t *testing.T) {
+	ciphertext := testdata.BenchCiphertexts[16]
+
+	opts := DefaultCrackOptions()
+	opts.Crib = "SUNSET"
+	opts.DictionaryAttack = false
+	opts.Seed = 42
+	opts.Threads = 2
+	opts.TriesPerEpoch = 64
+	opts.Epochs = 10
+	opts.ScoreGate = -1
+	opts.TopN = 5
+
+	result, err := PlayfairCrack(context.Background(), ciphertext, opts)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.Candidates)
+
+	// Every key searched holds the crib, which is left out of the score
+	constraint := newCribConstraint([]byte(ciphertext), []byte(opts.Crib), 'X', nil)
+	scorer := score.GetFoldedNgramScorer('J', 'I')
+	for _, candidate := range result.Candidates {
+		assert.GreaterOrEqual(t, constraint.fit(testKey(candidate.Key)), 0, candidate.Key)
+		assert.Equal(t, scorer.Score([]byte(candidate.Plaintext), 'X'), candidate.Score)
+	}
+}
+
+func TestGridSolverRejectsContradiction(t *testing.T) {
+	// AB cannot encrypt to both CD and CE
+	relations := []digraphRelation{
+		{plain: [2]byte{'A', 'B'}, cipher: [2]byte{'C', 'D'}},
+		{plain: [2]byte{'B', 'A'}, cipher: [2]byte{'E', 'C'}},
+	}
+	solver := newGridSolver(relations, 0)
+	_, ok := solver.solve()
+	assert.False(t, ok)
+	assert.False(t, solver.exhausted)
+}
+
+func TestRecoverKey(t *testing.T) {
+	ciphertext := []byte(testdata.BenchCiphertexts[16])
+	plaintext := []byte(testdata.BenchPlaintexts[16])
+
+	key := testKey(testdata.BenchKeys[16])
+
+	recovery, err := RecoverKey(plaintext, ciphertext, 'J')
+	assert.NoError(t, err)
+	assert.True(t, recovery.Determined)
+	assert.True(t, recovery.Verified)
+	assert.Empty(t, recovery.Ambiguous)
+	assert.Equal(t, 1, recovery.Solutions)
+	assert.True(t, key.Equivalent(testKey(recovery.Key)))
+
+	// A short pair leaves cells open but still round trips
+	recovery, err = RecoverKey(plaintext[:20], ciphertext[:20], 'J')
+	assert.NoError(t, err)
+	assert.False(t, recovery.Determined)
+	assert.True(t, recovery.Verified)
+	assert.NotEmpty(t, recovery.Ambiguous)
+
+	// Swapping two ciphertext digraphs breaks the pair
+	broken := slices.Clone(ciphertext)
+	broken[0], broken[1], broken[2], broken[3] = broken[2], broken[3], broken[0], broken[1]
+	_, err = RecoverKey(plaintext, broken, 'J')
+	assert.Error(t, err)
+}
+
+func TestPlayfairCrackExhaustive(t *testing.T) {
+	key := "RSBQLVECTIAWPNGKFYOZHXDMU"
+
+	// Leave the last row free
+	opts := DefaultCrackOptions()
+	copy(opts.Pins[:20], key)
+
+	result, err := PlayfairCrack(context.Background(), testdata.BenchCiphertexts[16], opts)
+	assert.NoError(t, err)
+	assert.Equal(t, key, result.Key)
+	assert.Equal(t, testdata.BenchPlaintexts[16], result.Plaintext)
+}
+
+func TestDictionaryAttack(t *testing.T) {
+	if testing.Short() {
+		t.Skip("scores every dictionary keyword")
+	}
+
+	key, err := cipher.ParseKey("harbour", 'J')
+	assert.NoError(t, err)
+	ciphertext := key.Encrypt([]byte(testdata.BenchPlaintexts[16]))
+
+	globalData := &globalData{
+		leaders:         newLeaderboard(0, cipher.PLAYFAIR),
+		scorer:          score.GetFoldedNgramScorer('J', 'I'),
+		ciphertext:      ciphertext,
+		layout:          cipher.StandardLayout('J'),
+		periods:         []int{0},
+		excludedLetter:  'J',
+		separatorLetter: 'X',
+		opts:            DefaultCrackOptions(),
+	}
+
+	_, seeds := dictionaryAttack(context.Background(), globalData, 4)
+	assert.Len(t, seeds, DICTIONARY_SEEDS)
+	assert.Equal(t, playfairKeys(key, 0), seeds[0].keys)
+}
+
+func TestRecoverKeyword(t *testing.T) {
+	key := cipher.KeywordKey("harbour", 'J')
+
+	recovery, err := RecoverKeyword(cipher.NewKey(cipher.RotateKey(key, 2, 3), 'J'), 'I')
+	assert.NoError(t, err)
+	assert.Equal(t, "HARBOU", recovery.Keyword)
+	assert.Equal(t, "harbour", recovery.Phrase)
+	assert.Equal(t, string(key[:]), recovery.Key)
+	assert.Equal(t, 19, recovery.Tail)
+
+	_, err = RecoverKeyword(testKey("RSBQLVECTIAWPNGKFYOZHXDMU"), 'I')
+	assert.Error(t, err)
+}
@@ -0,0 +1,159 @@
+package crack
+
+import (
+	"math/rand"
+	"playfaircrack/internal/cipher"
+)
+
+const (
+	// Search budget when checking a crib placement for consistency
+	CRIB_SOLVER_NODES int = 100000
+)
+
+// cribPlacement is a crib laid over the ciphertext starting at the aligned
+// offset, with the grid it implies when one could be found.
+type cribPlacement struct {
+	offset    int
+	relations []digraphRelation
+	grid      gridState
+	solved    bool
+}
+
+type cribConstraint struct {
+	placements []cribPlacement
+}
+
+// cribDigraphs splits crib into plaintext digraphs the way
+// ValidateAndTransformPlaintext would if the crib started on a digraph
+// boundary, separating aligned double letters. A trailing single letter is
+// dropped as its partner is unknown.
+func cribDigraphs(crib []byte, separatorLetter byte) [][2]byte {
+	var digraphs [][2]byte
+	for i := 0; i+1 < len(crib); {
+		if crib[i] == crib[i+1] {
+			digraphs = append(digraphs, [2]byte{crib[i], separatorLetter})
+			i++
+		} else {
+			digraphs = append(digraphs, [2]byte{crib[i], crib[i+1]})
+			i += 2
+		}
+	}
+	return digraphs
+}
+
+// newCribConstraint tries crib at every digraph aligned offset of the
+// ciphertext, both starting on a digraph and halfway through one, and keeps
+// the placements whose implied relations are consistent with some grid
+// holding pins, nil when no cells are pinned.
+func newCribConstraint(ciphertext []byte, crib []byte, separatorLetter byte, pins *cipher.PinnedCells) *cribConstraint {
+	constraint := &cribConstraint{}
+
+	// Starting halfway through a digraph drops the first letter
+	for _, aligned := range [][]byte{crib, crib[1:]} {
+		digraphs := cribDigraphs(aligned, separatorLetter)
+		if len(digraphs) == 0 {
+			continue
+		}
+
+		for offset := 0; offset+2*len(digraphs) <= len(ciphertext); offset += 2 {
+			placement, ok := placeCrib(ciphertext, digraphs, offset, pins)
+			if ok {
+				constraint.placements = append(constraint.placements, placement)
+			}
+		}
+	}
+
+	return constraint
+}
+
+// placeCrib derives the relations implied by digraphs sitting at offset and
+// reports whether they can hold alongside pins.
+func placeCrib(ciphertext []byte, digraphs [][2]byte, offset int, pins *cipher.PinnedCells) (cribPlacement, bool) {
+	placement := cribPlacement{offset: offset}
+
+	var ok bool
+	placement.relations, ok = digraphRelations(ciphertext[offset:offset+2*len(digraphs)], digraphs)
+	if !ok {
+		return placement, false
+	}
+
+	// Look for a grid satisfying every relation at once, around the pins
+	start := newGridState()
+	if pins != nil {
+		for cell, l := range pins {
+			if l != 0 {
+				start.place(l, cell)
+			}
+		}
+	}
+	solver := newGridSolver(placement.relations, CRIB_SOLVER_NODES)
+	placement.grid, placement.solved = solver.solveFrom(start)
+	if !placement.solved && !solver.exhausted {
+		return placement, false
+	}
+
+	return placement, true
+}
+
+// holds reports whether key encrypts every crib digraph of placement to the
+// ciphertext under it.
+func (placement *cribPlacement) holds(key cipher.Key) bool {
+	for _, relation := range placement.relations {
+		e1, e2 := digraphCells(key.Position(relation.plain[0]), key.Position(relation.plain[1]), 1)
+		if key.At(e1) != relation.cipher[0] || key.At(e2) != relation.cipher[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// fit returns the index of the first placement key holds, -1 when it holds
+// none of them.
+func (constraint *cribConstraint) fit(key cipher.Key) int {
+	for i := range constraint.placements {
+		if constraint.placements[i].holds(key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// solved reports whether a grid was found for any placement, without which
+// no key holding the crib can be built.
+func (constraint *cribConstraint) solved() bool {
+	for _, placement := range constraint.placements {
+		if placement.solved {
+			return true
+		}
+	}
+	return false
+}
+
+// randomKey fills the grid of a random solved placement with the remaining
+// letters in random order, the key holding the placement and any pins. There
+// must be a solved placement.
+func (constraint *cribConstraint) randomKey(rng *rand.Rand, excludedLetter byte) cipher.Key {
+	var solved []*cribPlacement
+	for i := range constraint.placements {
+		if constraint.placements[i].solved {
+			solved = append(solved, &constraint.placements[i])
+		}
+	}
+	state := solved[rng.Intn(len(solved))].grid
+
+	var remaining []byte
+	for l := byte('A'); l <= 'Z'; l++ {
+		if l != excludedLetter && state.placed(l) < 0 {
+			remaining = append(remaining, l)
+		}
+	}
+	rng.Shuffle(len(remaining), func(i, j int) { remaining[i], remaining[j] = remaining[j], remaining[i] })
+
+	key := state.cells
+	for i := range key {
+		if key[i] == 0 {
+			key[i], remaining = remaining[0], remaining[1:]
+		}
+	}
+	return cipher.NewKey(key, excludedLetter)
+}
@@ -0,0 +1,156 @@
+package crack
+
+import (
+	"context"
+	"fmt"
+	"playfaircrack/assets"
+	"playfaircrack/internal/cipher"
+	"strings"
+	"sync"
+)
+
+const (
+	// The most common words are also tried in pairs, as two word keyphrases
+	DICTIONARY_PHRASE_WORDS int = 300
+	// Best keyword grids kept to verify and to seed the annealing from
+	DICTIONARY_SEEDS int = 64
+	// Best keyword grids checked for English before falling back on annealing
+	DICTIONARY_VERIFY int = 8
+)
+
+// dictionaryKeywords returns every dictionary word, then every pair of the
+// most common words, dropping keywords that build a grid already listed.
+// Keywords are reduced to their distinct letters as keywordLetters does.
+func dictionaryKeywords(excludedLetter byte, replacementLetter byte) []string {
+	var keywords []string
+	dictionaryPhrases(excludedLetter, replacementLetter, func(keyword string, phrase string) {
+		keywords = append(keywords, keyword)
+	})
+	return keywords
+}
+
+// dictionaryPhrases calls visit with the keyword letters and the spelling of
+// every dictionary word, then every pair of the most common words, most
+// common first. Only the first phrase giving each keyword is visited.
+func dictionaryPhrases(excludedLetter byte, replacementLetter byte, visit func(keyword string, phrase string)) {
+	words := strings.Fields(assets.Dictionary)
+
+	seen := make(map[string]bool)
+	add := func(phrase string) {
+		keyword := keywordLetters(phrase, excludedLetter, replacementLetter)
+		if keyword == "" || seen[keyword] {
+			return
+		}
+		seen[keyword] = true
+		visit(keyword, phrase)
+	}
+
+	for _, word := range words {
+		add(word)
+	}
+
+	common := words[:min(DICTIONARY_PHRASE_WORDS, len(words))]
+	for _, first := range common {
+		for _, second := range common {
+			add(first + " " + second)
+		}
+	}
+}
+
+// keywordLetters upper cases the letters of word, replacing the excluded
+// letter and dropping repeats, as they are laid into the grid. The excluded
+// letter is dropped too when replacementLetter is 0.
+func keywordLetters(word string, excludedLetter byte, replacementLetter byte) string {
+	var used [26]bool
+	var letters []byte
+	for _, l := range []byte(strings.ToUpper(word)) {
+		if l == excludedLetter {
+			l = replacementLetter
+		}
+		if l < 'A' || l > 'Z' || used[l-'A'] {
+			continue
+		}
+		used[l-'A'] = true
+		letters = append(letters, l)
+	}
+	return string(letters)
+}
+
+// dictionaryAttack scores the grid of every dictionary keyword across
+// numThreads workers and checks the best few for English. It returns the
+// confirmed solution if one was found, and in any case the best keyword
+// grids seen, best first, to seed the annealing from.
+func dictionaryAttack(ctx context.Context, globalData *globalData, numThreads int) (CrackResult, []keyData) {
+	keywords := dictionaryKeywords(globalData.excludedLetter, globalData.opts.ReplacementLetter)
+	if globalData.opts.LogVerbose {
+		fmt.Printf("Trying %d dictionary keywords\n\n", len(keywords))
+	}
+
+	best := newLeaderboard(DICTIONARY_SEEDS, cipher.PLAYFAIR)
+
+	var waitGroup sync.WaitGroup
+	for t := 0; t < numThreads; t++ {
+		waitGroup.Add(1)
+		go func(t int) {
+			defer waitGroup.Done()
+
+			// Keep a board per worker to stay off the shared lock
+			local := newLeaderboard(DICTIONARY_SEEDS, cipher.PLAYFAIR)
+			defer func() {
+				for _, keyData := range local.keys {
+					best.offer(keyData)
+				}
+			}()
+
+			for i := t; i < len(keywords); i += numThreads {
+				if i%1024 < numThreads {
+					select {
+					case <-ctx.Done():
+						return
+					default:
+					}
+				}
+
+				key := globalData.layout.KeywordKey(keywords[i])
+				if globalData.pins != nil && !pinsHold(key, globalData.pins) {
+					continue
+				}
+				keys := playfairKeys(key, globalData.periods[0])
+				if !globalData.holdsCrib(keys) {
+					continue
+				}
+				local.offer(keyData{score: globalData.scoreKey(keys), keys: keys})
+			}
+		}(t)
+	}
+	waitGroup.Wait()
+
+	for _, keyData := range best.keys {
+		globalData.leaders.offer(keyData)
+	}
+
+	// Only the best few are worth the slow check
+	for i, keyData := range best.keys {
+		if i == DICTIONARY_VERIFY || ctx.Err() != nil {
+			break
+		}
+		if solution, ok := checkForSolution(globalData, keyData.keys); ok {
+			return solution, best.keys
+		}
+		if globalData.opts.LogVerbose {
+			fmt.Printf("Rejected keyword grid %s, score %2.2f\n", keyData.keys, keyData.score)
+		}
+	}
+
+	return CrackResult{}, best.keys
+}
+
+// pinsHold reports whether key has every pinned letter in its cell.
+func pinsHold(key cipher.Key, pins *cipher.PinnedCells) bool {
+	for cell, l := range pins {
+		if l != 0 && key.Position(l) != cell {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package crack
+
+import (
+	"context"
+	"math"
+	"playfaircrack/internal/cipher"
+)
+
+// exhaustiveCrack scores every way of filling the free cells of the pinned
+// key that holds the crib and checks the best one, stopping early if ctx is
+// done.
+func exhaustiveCrack(ctx context.Context, globalData *globalData, free []int) CrackResult {
+	key := [25]byte(globalData.opts.Pins)
+
+	var letters []byte
+	for l := byte('A'); l <= 'Z'; l++ {
+		if l != globalData.excludedLetter && !globalData.opts.Pins.IsPinned(l) {
+			letters = append(letters, l)
+		}
+	}
+
+	period := globalData.periods[0]
+	best := keyData{score: math.Inf(-1)}
+	try := func() {
+		candidate := playfairKeys(cipher.NewKey(fillCells(key, free, letters), globalData.excludedLetter), period)
+		if !globalData.holdsCrib(candidate) {
+			return
+		}
+		candidateScore := globalData.scoreKey(candidate)
+		globalData.leaders.offer(keyData{score: candidateScore, keys: candidate})
+		if candidateScore > best.score {
+			best = keyData{score: candidateScore, keys: candidate}
+		}
+	}
+	try()
+
+	// Heap's algorithm, visiting each permutation of letters once
+	counters := make([]int, len(letters))
+	for i, tries := 0, 0; i < len(letters); tries++ {
+		if tries%1024 == 0 && ctx.Err() != nil {
+			break
+		}
+
+		if counters[i] >= i {
+			counters[i] = 0
+			i++
+			continue
+		}
+
+		if i%2 == 0 {
+			letters[0], letters[i] = letters[i], letters[0]
+		} else {
+			letters[counters[i]], letters[i] = letters[i], letters[counters[i]]
+		}
+		counters[i]++
+		i = 0
+
+		try()
+	}
+
+	// The crib held for none of them
+	if math.IsInf(best.score, -1) {
+		return CrackResult{Score: best.score}
+	}
+
+	if solution, ok := checkForSolution(globalData, best.keys); ok {
+		return solution
+	}
+	return unconfirmedResult(globalData, best)
+}
+
+// fillCells puts letters into the free cells of key, in order.
+func fillCells(key [25]byte, free []int, letters []byte) [25]byte {
+	for i, cell := range free {
+		key[cell] = letters[i]
+	}
+	return key
+}
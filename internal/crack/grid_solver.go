@@ -0,0 +1,244 @@
+package crack
+
+import "slices"
+
+// digraphRelation records that the plaintext digraph plain encrypts to the
+// ciphertext digraph cipher under the key being solved for.
+type digraphRelation struct {
+	plain  [2]byte
+	cipher [2]byte
+}
+
+// digraphRelations pairs every plaintext digraph with the ciphertext under it,
+// dropping repeats. It reports false if the pairs contradict one another
+// without needing a grid: a letter encrypting to itself, or a digraph (or its
+// reverse) encrypting two different ways.
+func digraphRelations(ciphertext []byte, digraphs [][2]byte) ([]digraphRelation, bool) {
+	var relations []digraphRelation
+
+	encrypts := make(map[[2]byte][2]byte)
+	decrypts := make(map[[2]byte][2]byte)
+	for i, plain := range digraphs {
+		cipher := [2]byte{ciphertext[2*i], ciphertext[2*i+1]}
+
+		// Playfair never encrypts a letter to itself
+		if plain[0] == cipher[0] || plain[1] == cipher[1] {
+			return nil, false
+		}
+
+		// Seen before, nothing new to learn
+		if known, ok := encrypts[plain]; ok && known == cipher {
+			continue
+		}
+
+		// Digraphs encrypt the same way every time, and reversed digraphs
+		// encrypt to the reversed digraph
+		reversedPlain := [2]byte{plain[1], plain[0]}
+		reversedCipher := [2]byte{cipher[1], cipher[0]}
+		for _, pair := range [][2][2]byte{{plain, cipher}, {reversedPlain, reversedCipher}} {
+			if known, ok := encrypts[pair[0]]; ok && known != pair[1] {
+				return nil, false
+			}
+			if known, ok := decrypts[pair[1]]; ok && known != pair[0] {
+				return nil, false
+			}
+			encrypts[pair[0]] = pair[1]
+			decrypts[pair[1]] = pair[0]
+		}
+
+		relations = append(relations, digraphRelation{plain: plain, cipher: cipher})
+	}
+
+	return relations, true
+}
+
+// gridState is a partially filled key grid, cells holds 0 where no letter has
+// been placed and pos holds -1 for letters that have not been placed.
+type gridState struct {
+	cells [25]byte
+	pos   [26]int8
+}
+
+func newGridState() gridState {
+	var state gridState
+	for i := range state.pos {
+		state.pos[i] = -1
+	}
+	return state
+}
+
+// place puts letter in cell, reporting false if either is already taken by
+// something else.
+func (state *gridState) place(letter byte, cell int) bool {
+	if state.cells[cell] != 0 {
+		return state.cells[cell] == letter
+	}
+	if pos := state.pos[letter-'A']; pos >= 0 {
+		return int(pos) == cell
+	}
+
+	state.cells[cell] = letter
+	state.pos[letter-'A'] = int8(cell)
+	return true
+}
+
+func (state *gridState) placed(letter byte) int {
+	return int(state.pos[letter-'A'])
+}
+
+// digraphCells returns the cells a digraph in cells a and b maps to, shift is
+// 1 to encrypt and -1 to decrypt, following the rules of PlayfairEncrypt.
+func digraphCells(a, b int, shift int) (int, int) {
+	rowA, colA := a/5, a%5
+	rowB, colB := b/5, b%5
+
+	if rowA == rowB {
+		return rowA*5 + (colA+shift+5)%5, rowB*5 + (colB+shift+5)%5
+	} else if colA == colB {
+		return ((rowA+shift+5)%5)*5 + colA, ((rowB+shift+5)%5)*5 + colB
+	}
+	return rowA*5 + colB, rowB*5 + colA
+}
+
+// couldEncryptTo reports whether a letter in cell plain could encrypt to one
+// in cell cipher, either in its row (same row or rectangle) or just below it
+// (same column).
+func couldEncryptTo(plain, cipher int) bool {
+	if plain == cipher {
+		return false
+	}
+	return plain/5 == cipher/5 || cipher == ((plain/5+1)%5)*5+plain%5
+}
+
+// gridSolver searches for key grids consistent with a set of digraph
+// relations. Grids are only found up to rotation, the first letter placed is
+// always put in the top left cell.
+type gridSolver struct {
+	relations []digraphRelation
+	order     []byte
+	nodes     int
+	maxNodes  int
+	exhausted bool
+}
+
+func newGridSolver(relations []digraphRelation, maxNodes int) *gridSolver {
+	// Place the most constrained letters first
+	var counts [26]int
+	for _, relation := range relations {
+		for _, l := range []byte{relation.plain[0], relation.plain[1], relation.cipher[0], relation.cipher[1]} {
+			counts[l-'A']++
+		}
+	}
+
+	var order []byte
+	for n := len(relations) * 4; n > 0; n-- {
+		for i, count := range counts {
+			if count == n {
+				order = append(order, byte('A'+i))
+			}
+		}
+	}
+
+	return &gridSolver{
+		relations: relations,
+		order:     order,
+		maxNodes:  maxNodes,
+	}
+}
+
+// propagate places every letter forced by a relation whose plaintext or
+// ciphertext pair is fully placed, reporting false on a contradiction.
+func (solver *gridSolver) propagate(state *gridState) bool {
+	for changed := true; changed; {
+		changed = false
+		for _, relation := range solver.relations {
+			p1, p2 := state.placed(relation.plain[0]), state.placed(relation.plain[1])
+			c1, c2 := state.placed(relation.cipher[0]), state.placed(relation.cipher[1])
+
+			if p1 >= 0 && p2 >= 0 {
+				e1, e2 := digraphCells(p1, p2, 1)
+				if !state.place(relation.cipher[0], e1) || !state.place(relation.cipher[1], e2) {
+					return false
+				}
+				changed = changed || c1 < 0 || c2 < 0
+			} else if c1 >= 0 && c2 >= 0 {
+				e1, e2 := digraphCells(c1, c2, -1)
+				if !state.place(relation.plain[0], e1) || !state.place(relation.plain[1], e2) {
+					return false
+				}
+				changed = true
+			} else {
+				if p1 >= 0 && c1 >= 0 && !couldEncryptTo(p1, c1) {
+					return false
+				}
+				if p2 >= 0 && c2 >= 0 && !couldEncryptTo(p2, c2) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// search calls visit with every consistent grid reachable from state until
+// visit returns false or the node budget runs out. It returns false once the
+// search should stop.
+func (solver *gridSolver) search(state gridState, visit func(gridState) bool) bool {
+	solver.nodes++
+	if solver.maxNodes > 0 && solver.nodes > solver.maxNodes {
+		solver.exhausted = true
+		return false
+	}
+
+	if !solver.propagate(&state) {
+		return true
+	}
+
+	// Pick the next letter to place
+	var letter byte
+	for _, l := range solver.order {
+		if state.placed(l) < 0 {
+			letter = l
+			break
+		}
+	}
+	if letter == 0 {
+		return visit(state)
+	}
+	anyPlaced := slices.ContainsFunc(state.cells[:], func(l byte) bool { return l != 0 })
+
+	for cell := range state.cells {
+		if state.cells[cell] != 0 {
+			continue
+		}
+
+		next := state
+		next.place(letter, cell)
+		if !solver.search(next, visit) {
+			return false
+		}
+
+		// Fix the rotation with the first letter
+		if !anyPlaced {
+			break
+		}
+	}
+	return true
+}
+
+// solve returns a partial grid consistent with every relation. When ok is
+// false the relations are contradictory, unless the node budget ran out
+// first in which case exhausted is set.
+func (solver *gridSolver) solve() (grid gridState, ok bool) {
+	return solver.solveFrom(newGridState())
+}
+
+// solveFrom is solve for grids holding the letters already placed in start.
+// Letters placed in start fix the rotation of the grids found.
+func (solver *gridSolver) solveFrom(start gridState) (grid gridState, ok bool) {
+	solver.search(start, func(state gridState) bool {
+		grid, ok = state, true
+		return false
+	})
+	return grid, ok
+}
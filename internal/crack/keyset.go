@@ -0,0 +1,86 @@
+package crack
+
+import (
+	"math/rand"
+	"playfaircrack/internal/cipher"
+)
+
+// keySet is the key of the cipher being cracked: its grids as a pair, with the
+// second left empty for Playfair, and the Seriated Playfair period, 0 when
+// the ciphertext is not seriated.
+type keySet struct {
+	grids  [2]cipher.Key
+	period int
+}
+
+// playfairKeys is the key set of a single Playfair grid.
+func playfairKeys(key cipher.Key, period int) keySet {
+	return keySet{grids: [2]cipher.Key{key}, period: period}
+}
+
+// String returns the symbols of every grid, grids separated by a space.
+func (keys keySet) String() string {
+	if keys.grids[1].Size() == 0 {
+		return keys.grids[0].String()
+	}
+	return keys.grids[0].String() + " " + keys.grids[1].String()
+}
+
+// canonical returns the same keys for every key set known to decrypt the same
+// way under cipherType. The rotations of a Playfair grid are folded together,
+// as are Double Playfair and Horizontal Two-Square grids with their rows moved
+// together and their columns each on their own: the squares share their rows,
+// but only look cells up by column within each square. Vertical Two-Square
+// grids fold the other way around, sharing their columns. Four-Square grids
+// are returned as they are.
+func (keys keySet) canonical(cipherType cipher.Type) keySet {
+	switch cipherType {
+	case cipher.PLAYFAIR:
+		keys.grids[0] = keys.grids[0].Canonical()
+	case cipher.DOUBLE_PLAYFAIR, cipher.TWO_SQUARE:
+		first := keys.grids[0].Canonical()
+		rows := keys.grids[0].Position(first.At(0)) / first.Cols()
+		second := keys.grids[1].Rotate(rows, 0)
+		cols := second.Position(second.Canonical().At(0)) % second.Cols()
+		keys.grids[0], keys.grids[1] = first, second.Rotate(0, cols)
+	case cipher.TWO_SQUARE_VERTICAL:
+		first := keys.grids[0].Canonical()
+		cols := keys.grids[0].Position(first.At(0)) % first.Cols()
+		second := keys.grids[1].Rotate(0, cols)
+		rows := second.Position(second.Canonical().At(0)) / second.Cols()
+		keys.grids[0], keys.grids[1] = first, second.Rotate(rows, 0)
+	}
+	return keys
+}
+
+// equivalent reports whether keys and other decrypt the same way under
+// cipherType, as far as canonical knows.
+func (keys keySet) equivalent(other keySet, cipherType cipher.Type) bool {
+	return keys.canonical(cipherType) == other.canonical(cipherType)
+}
+
+// permute changes one of the first grids of keys at random, see
+// cipher.Key.Permute. A single grid is permuted without drawing on rng to
+// pick it. The period never changes.
+func (keys keySet) permute(rng *rand.Rand, grids int, pins *cipher.PinnedCells) keySet {
+	i := 0
+	if grids > 1 {
+		i = rng.Intn(grids)
+	}
+	keys.grids[i] = keys.grids[i].Permute(rng, pins)
+	return keys
+}
+
+// seriationPeriods returns every Seriated Playfair period ciphertext could
+// have been encrypted with, shortest first: those leaving no column of it
+// doubled. Periods of half the ciphertext or more all write it as one group,
+// only the first of them is returned.
+func seriationPeriods(ciphertext []byte) []int {
+	var periods []int
+	for period := 2; period <= len(ciphertext)/2; period++ {
+		if !cipher.HasDoubledColumns(ciphertext, period) {
+			periods = append(periods, period)
+		}
+	}
+	return periods
+}
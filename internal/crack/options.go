@@ -0,0 +1,251 @@
+package crack
+
+import (
+	"fmt"
+	"playfaircrack/internal/cipher"
+	"slices"
+)
+
+// CrackOptions configures a call to PlayfairCrack.
+type CrackOptions struct {
+	// ExcludedLetter is left out of the grid, the plaintext had it replaced
+	// by ReplacementLetter, or dropped when ReplacementLetter is 0. Scoring
+	// folds the English ngrams the same way.
+	ExcludedLetter    byte
+	ReplacementLetter byte
+	SeparatorLetter   byte
+	LogVerbose        bool
+
+	// Layout is the shape and alphabet of the key grid, the zero value being
+	// the usual 5x5 grid leaving out ExcludedLetter. Cribs, pins and the
+	// dictionary attack only work on 5x5 grids of letters.
+	Layout cipher.Layout
+
+	// Cipher is the cipher the ciphertext was encrypted with, every one of
+	// its grids is searched at once. Cribs, pins and the dictionary attack
+	// only work on Playfair.
+	Cipher cipher.Type
+
+	// Period is the Seriated Playfair period of the ciphertext, 0 when it is
+	// not seriated. SearchPeriods cracks a seriated ciphertext of unknown
+	// period instead, under every period leaving no column of the ciphertext
+	// doubled at once, pools taking turns at them when there are more periods
+	// than pools.
+	Period        int
+	SearchPeriods bool
+
+	// Rules are the rules the Playfair grids of Cipher map digraphs by, see
+	// cipher.Rules. Cribs only work under the standard rules.
+	Rules cipher.Rules
+
+	// Seed drives every random choice of the search, each worker gets its own
+	// source seeded from it. Zero picks a seed from the clock, the seed used is
+	// reported in CrackResult.Seed either way.
+	Seed int64
+
+	// Crib is a word known to be somewhere in the plaintext, in upper case
+	// with the excluded letter already replaced. Only keys encrypting it at
+	// one of the placements it fits in the ciphertext are searched.
+	Crib string
+
+	// Pins holds letters known to sit in given cells of the key, the search
+	// never moves them. When ExhaustiveLimit or fewer cells are left free
+	// every way of filling them is tried instead of annealing.
+	Pins            cipher.PinnedCells
+	ExhaustiveLimit int
+
+	// DictionaryAttack tries the grid of every dictionary word and common
+	// two word phrase as the keyword before annealing, the annealing then
+	// starts from the best of them.
+	DictionaryAttack bool
+
+	// Simulated annealing schedule, the temperature starts at InitialTemp and
+	// is multiplied by (1 - CoolingRate) every epoch until it drops below
+	// FloorTemp, at which point the worker restarts from its pool.
+	InitialTemp   float64
+	FloorTemp     float64
+	CoolingRate   float64
+	TriesPerEpoch int
+
+	// Epochs caps the epochs every worker anneals for, zero for no cap. The
+	// crack stops once every worker has run them, so that a capped crack
+	// confirming no key always ends the same way for the same Seed, Threads
+	// and PoolSize.
+	Epochs int
+
+	// A worker whose best score is above ScoreGate and has not improved in
+	// TriesBeforeStagnation tries stops annealing and submits its best key.
+	TriesBeforeStagnation int
+	ScoreGate             float64
+
+	// TopN is the number of best distinct keys to report in
+	// CrackResult.Candidates, zero to report none.
+	TopN int
+
+	// Threads is the total number of annealing workers, zero meaning one per
+	// CPU. They are split into pools of PoolSize, the last pool holding the
+	// remainder when Threads is not a multiple of PoolSize.
+	Threads int
+
+	// Workers in a pool share their current keys, picking one another's keys
+	// at GeneticTempMultiplier times the current temperature.
+	GeneticTempMultiplier float64
+	PoolSize              int
+}
+
+// DefaultCrackOptions returns the options used by the crack command when no
+// flags are given.
+func DefaultCrackOptions() CrackOptions {
+	return CrackOptions{
+		ExcludedLetter:        'J',
+		ReplacementLetter:     'I',
+		SeparatorLetter:       'X',
+		LogVerbose:            false,
+		Seed:                  0,
+		Crib:                  "",
+		ExhaustiveLimit:       8,
+		DictionaryAttack:      true,
+		InitialTemp:           50,
+		FloorTemp:             0.1,
+		CoolingRate:           0.01,
+		TriesPerEpoch:         1024,
+		TriesBeforeStagnation: 50000,
+		ScoreGate:             -3000,
+		TopN:                  0,
+		Threads:               0,
+		GeneticTempMultiplier: 5,
+		PoolSize:              4,
+	}
+}
+
+// layout returns Layout, or the standard layout when it is not set.
+func (opts CrackOptions) layout() cipher.Layout {
+	if opts.Layout.Rows == 0 && opts.Layout.Cols == 0 {
+		return cipher.StandardLayout(opts.ExcludedLetter)
+	}
+	return opts.Layout
+}
+
+// Validate reports the first option that is out of range.
+func (opts CrackOptions) Validate() error {
+	if opts.Period < 0 {
+		return fmt.Errorf("The period must not be negative, got %d", opts.Period)
+	}
+	if opts.SearchPeriods && opts.Cipher.AllowsDoubles() {
+		return fmt.Errorf("Periods can only be searched for ciphers never encrypting to doubled digraphs")
+	}
+	if (opts.Period > 1 || opts.SearchPeriods) && opts.Crib != "" {
+		return fmt.Errorf("Cribs are not supported on seriated ciphertexts")
+	}
+	if opts.Rules != cipher.STANDARD_RULES {
+		if opts.Cipher != cipher.PLAYFAIR {
+			return fmt.Errorf("Rules other than the standard ones only apply to Playfair")
+		}
+		if opts.Crib != "" {
+			return fmt.Errorf("Cribs are only supported under the standard rules")
+		}
+	}
+	if opts.Cipher != cipher.PLAYFAIR {
+		if opts.Crib != "" {
+			return fmt.Errorf("Cribs are only supported on Playfair")
+		}
+		if len(opts.Pins.FreeCells()) < 25 {
+			return fmt.Errorf("Pinned cells are only supported on Playfair")
+		}
+	}
+	if layout := opts.layout(); !layout.IsStandard() {
+		return opts.validateLayout(layout)
+	}
+	if opts.Layout.Rows != 0 && opts.Layout.Excluded() != opts.ExcludedLetter {
+		return fmt.Errorf("The layout leaves out %c, not the excluded letter %c", opts.Layout.Excluded(), opts.ExcludedLetter)
+	}
+	if opts.ExcludedLetter < 'A' || opts.ExcludedLetter > 'Z' {
+		return fmt.Errorf("The excluded letter must be in A-Z, got %q", opts.ExcludedLetter)
+	}
+	if opts.ReplacementLetter != 0 && (opts.ReplacementLetter < 'A' || opts.ReplacementLetter > 'Z' || opts.ReplacementLetter == opts.ExcludedLetter) {
+		return fmt.Errorf("The replacement letter must be in A-Z and not excluded, got %q", opts.ReplacementLetter)
+	}
+	if opts.SeparatorLetter < 'A' || opts.SeparatorLetter > 'Z' || opts.SeparatorLetter == opts.ExcludedLetter {
+		return fmt.Errorf("The separator letter must be in A-Z and not excluded, got %q", opts.SeparatorLetter)
+	}
+	for _, l := range []byte(opts.Crib) {
+		if l < 'A' || l > 'Z' || l == opts.ExcludedLetter {
+			return fmt.Errorf("The crib must only contain letters in A-Z other than %c, got %q", opts.ExcludedLetter, l)
+		}
+	}
+	if len(opts.Crib) == 1 {
+		return fmt.Errorf("The crib must be at least two letters long")
+	}
+	for cell, l := range opts.Pins {
+		if l == 0 {
+			continue
+		}
+		if l < 'A' || l > 'Z' || l == opts.ExcludedLetter {
+			return fmt.Errorf("Pinned cells must only hold letters in A-Z other than %c, got %q", opts.ExcludedLetter, l)
+		}
+		if slices.Index(opts.Pins[:], l) != cell {
+			return fmt.Errorf("The letter %c is pinned to more than one cell", l)
+		}
+	}
+	return opts.validateSearch()
+}
+
+// validateLayout checks the options for a grid other than 5x5 letters, which
+// take no crib or pins.
+func (opts CrackOptions) validateLayout(layout cipher.Layout) error {
+	if err := layout.Validate(); err != nil {
+		return err
+	}
+	if !layout.Contains(opts.SeparatorLetter) {
+		return fmt.Errorf("The separator letter must be in the alphabet of the grid, got %q", opts.SeparatorLetter)
+	}
+	if opts.Crib != "" {
+		return fmt.Errorf("Cribs are only supported on 5x5 grids of letters")
+	}
+	if len(opts.Pins.FreeCells()) < 25 {
+		return fmt.Errorf("Pinned cells are only supported on 5x5 grids of letters")
+	}
+	return opts.validateSearch()
+}
+
+// validateSearch checks the options of the search itself.
+func (opts CrackOptions) validateSearch() error {
+	if opts.ExhaustiveLimit < 0 || opts.ExhaustiveLimit > 10 {
+		return fmt.Errorf("The exhaustive limit must be in [0, 10], got %d", opts.ExhaustiveLimit)
+	}
+	if opts.FloorTemp <= 0 {
+		return fmt.Errorf("The floor temperature must be positive, got %v", opts.FloorTemp)
+	}
+	if opts.InitialTemp <= opts.FloorTemp {
+		return fmt.Errorf("The initial temperature must be above the floor temperature %v, got %v", opts.FloorTemp, opts.InitialTemp)
+	}
+	if opts.CoolingRate <= 0 || opts.CoolingRate >= 1 {
+		return fmt.Errorf("The cooling rate must be in (0, 1), got %v", opts.CoolingRate)
+	}
+	if opts.TriesPerEpoch < 1 {
+		return fmt.Errorf("The tries per epoch must be at least 1, got %d", opts.TriesPerEpoch)
+	}
+	if opts.Epochs < 0 {
+		return fmt.Errorf("The number of epochs must not be negative, got %d", opts.Epochs)
+	}
+	if opts.TriesBeforeStagnation < 1 {
+		return fmt.Errorf("The tries before stagnation must be at least 1, got %d", opts.TriesBeforeStagnation)
+	}
+	if opts.ScoreGate >= 0 {
+		return fmt.Errorf("The score gate must be negative, got %v", opts.ScoreGate)
+	}
+	if opts.GeneticTempMultiplier <= 0 {
+		return fmt.Errorf("The genetic temperature multiplier must be positive, got %v", opts.GeneticTempMultiplier)
+	}
+	if opts.TopN < 0 {
+		return fmt.Errorf("The number of top candidates must not be negative, got %d", opts.TopN)
+	}
+	if opts.Threads < 0 {
+		return fmt.Errorf("The number of threads must not be negative, got %d", opts.Threads)
+	}
+	if opts.PoolSize < 1 {
+		return fmt.Errorf("The pool size must be at least 1, got %d", opts.PoolSize)
+	}
+
+	return nil
+}
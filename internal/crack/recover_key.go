@@ -0,0 +1,124 @@
+package crack
+
+import (
+	"bytes"
+	"fmt"
+	"playfaircrack/internal/cipher"
+)
+
+const (
+	// Grids to enumerate before giving up on counting them all
+	RECOVERY_MAX_SOLUTIONS int = 1000
+	// Search budget when recovering a key
+	RECOVERY_SOLVER_NODES int = 5000000
+)
+
+// KeyRecovery is a key grid reconstructed from a plaintext and its
+// ciphertext. Keys are only recovered up to rotation of rows and columns,
+// which all encrypt the same way.
+type KeyRecovery struct {
+	// Key has every ambiguous cell filled with the unplaced letters in
+	// alphabetical order
+	Key string
+	// Determined is set when exactly one grid fits and every cell is known
+	Determined bool
+	// Ambiguous lists the row major cells whose letter is not pinned down
+	Ambiguous []int
+	// Solutions counts the distinct grids found, Exhausted is set when the
+	// search stopped before finding all of them
+	Solutions int
+	Exhausted bool
+	// Verified is set when re-encrypting the plaintext with Key gives back
+	// the ciphertext
+	Verified bool
+}
+
+// RecoverKey reconstructs the key grid that encrypts plaintext to ciphertext
+// by propagating the row, column and rectangle relations of every digraph.
+// The plaintext must already be prepared for encryption, with separators and
+// padding in place.
+func RecoverKey(plaintext []byte, ciphertext []byte, excludedLetter byte) (*KeyRecovery, error) {
+	if len(plaintext) != len(ciphertext) {
+		return nil, fmt.Errorf("The plaintext and ciphertext must be the same length, got %d and %d", len(plaintext), len(ciphertext))
+	}
+	if len(plaintext)%2 != 0 {
+		return nil, fmt.Errorf("The plaintext and ciphertext must be aligned on the two letter boundary")
+	}
+
+	for i := range plaintext {
+		for _, l := range []byte{plaintext[i], ciphertext[i]} {
+			if l < 'A' || l > 'Z' || l == excludedLetter {
+				return nil, fmt.Errorf("The plaintext and ciphertext must only contain letters in A-Z other than %c, got %q", excludedLetter, l)
+			}
+		}
+	}
+
+	digraphs := make([][2]byte, len(plaintext)/2)
+	for i := range digraphs {
+		digraphs[i] = [2]byte{plaintext[2*i], plaintext[2*i+1]}
+		if digraphs[i][0] == digraphs[i][1] {
+			return nil, fmt.Errorf("The plaintext must not contain letter pairs aligned on the two letter boundary, %c%c", digraphs[i][0], digraphs[i][1])
+		}
+	}
+
+	relations, ok := digraphRelations(ciphertext, digraphs)
+	if !ok {
+		return nil, fmt.Errorf("The plaintext and ciphertext do not fit any Playfair key")
+	}
+
+	// Enumerate every grid, noting which cells they agree on
+	solver := newGridSolver(relations, RECOVERY_SOLVER_NODES)
+	var solutions []gridState
+	solver.search(newGridState(), func(state gridState) bool {
+		solutions = append(solutions, state)
+		return len(solutions) < RECOVERY_MAX_SOLUTIONS
+	})
+	if len(solutions) == 0 {
+		if solver.exhausted {
+			return nil, fmt.Errorf("Gave up searching for a key after %d steps", solver.nodes)
+		}
+		return nil, fmt.Errorf("The plaintext and ciphertext do not fit any Playfair key")
+	}
+
+	recovery := &KeyRecovery{
+		Solutions: len(solutions),
+		Exhausted: solver.exhausted || len(solutions) == RECOVERY_MAX_SOLUTIONS,
+	}
+
+	grid := solutions[0]
+	var remaining []byte
+	for l := byte('A'); l <= 'Z'; l++ {
+		if l != excludedLetter && grid.placed(l) < 0 {
+			remaining = append(remaining, l)
+		}
+	}
+
+	for cell, letter := range grid.cells {
+		agreed := letter != 0
+		for _, other := range solutions[1:] {
+			agreed = agreed && other.cells[cell] == letter
+		}
+		if !agreed {
+			recovery.Ambiguous = append(recovery.Ambiguous, cell)
+		}
+	}
+
+	// A single unplaced letter can only go in the single empty cell
+	if len(remaining) == 1 && len(solutions) == 1 {
+		recovery.Ambiguous = nil
+	}
+	recovery.Determined = len(recovery.Ambiguous) == 0 && !recovery.Exhausted
+
+	// Fill the gaps to get a usable key
+	var key [25]byte
+	for cell, letter := range grid.cells {
+		if letter == 0 {
+			letter, remaining = remaining[0], remaining[1:]
+		}
+		key[cell] = letter
+	}
+	recovery.Key = string(key[:])
+	recovery.Verified = bytes.Equal(cipher.PlayfairEncrypt(plaintext, key, excludedLetter), ciphertext)
+
+	return recovery, nil
+}
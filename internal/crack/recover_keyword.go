@@ -0,0 +1,95 @@
+package crack
+
+import (
+	"fmt"
+	"playfaircrack/internal/cipher"
+)
+
+const (
+	// Shortest alphabetical run ending a grid to take it for a keyword grid
+	KEYWORD_MIN_TAIL int = 8
+)
+
+// KeywordRecovery is the keyword a cracked key grid was probably built from.
+type KeywordRecovery struct {
+	// Keyword holds the distinct letters of the keyword, as they lead Key
+	Keyword string
+	// Phrase is the most common dictionary word, or pair of common words,
+	// spelling Keyword. It is empty when none does.
+	Phrase string
+	// Key is the rotation of the cracked grid that starts with Keyword
+	Key string
+	// Tail is the length of the alphabetical run Key ends with
+	Tail int
+}
+
+// keywordForm is one reading of a rotated grid as keyword and tail.
+type keywordForm struct {
+	key    [25]byte
+	tail   int
+	length int
+	phrase string
+}
+
+// RecoverKeyword looks through the rotations of key, which all encrypt the
+// same way, for one laid out from a keyword: the keyword letters followed by
+// the rest of the alphabet in order. A keyword spelling a dictionary word is
+// preferred, then the longest alphabetical tail. Dictionary words have the
+// excluded letter of key replaced by replacementLetter, or dropped when it
+// is 0.
+func RecoverKeyword(key cipher.Key, replacementLetter byte) (*KeywordRecovery, error) {
+	if key.Rows() != 5 || key.Cols() != 5 || key.Excluded() == 0 {
+		return nil, fmt.Errorf("Keywords can only be recovered from 5x5 grids of letters")
+	}
+
+	var forms []keywordForm
+	for rows := 0; rows < 5; rows++ {
+		for cols := 0; cols < 5; cols++ {
+			rotated := cipher.RotateKey([25]byte(key.Cells()), rows, cols)
+
+			tail := 1
+			for tail < 25 && rotated[24-tail] < rotated[25-tail] {
+				tail++
+			}
+			if tail >= KEYWORD_MIN_TAIL {
+				forms = append(forms, keywordForm{key: rotated, tail: tail, length: 25 - tail})
+			}
+		}
+	}
+	if len(forms) == 0 {
+		return nil, fmt.Errorf("No rotation of the key ends with %d or more letters in alphabetical order", KEYWORD_MIN_TAIL)
+	}
+
+	// The keyword may run on into the tail when it ends in order, so any
+	// split within the tail could be the one
+	phrases := make(map[string]string)
+	dictionaryPhrases(key.Excluded(), replacementLetter, func(keyword string, phrase string) {
+		phrases[keyword] = phrase
+	})
+	for i := range forms {
+		for length := forms[i].length; length < 25; length++ {
+			if phrase, ok := phrases[string(forms[i].key[:length])]; ok {
+				forms[i].length, forms[i].phrase = length, phrase
+				break
+			}
+		}
+	}
+
+	best := forms[0]
+	for _, form := range forms[1:] {
+		if (form.phrase != "") != (best.phrase != "") {
+			if form.phrase != "" {
+				best = form
+			}
+		} else if form.tail > best.tail {
+			best = form
+		}
+	}
+
+	return &KeywordRecovery{
+		Keyword: string(best.key[:best.length]),
+		Phrase:  best.phrase,
+		Key:     string(best.key[:]),
+		Tail:    best.tail,
+	}, nil
+}
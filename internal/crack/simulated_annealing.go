@@ -6,8 +6,6 @@ import (
 	"math"
 	"math/rand"
 	"os"
-	"playfaircrack/internal/cipher"
-	"playfaircrack/internal/score"
 	"time"
 
 	"golang.org/x/term"
@@ -18,56 +16,64 @@ func simulatedAnnealingCrack(
 	logVerbose bool,
 	poolData *poolData,
 	pid int,
-	startingKey [25]byte,
+	rng *rand.Rand,
+	current *keyData,
 	initialTemp float64,
 	floorTemp float64,
 	coolingRate float64,
 	triesPerEpoch int,
 	triesBeforeStagnation int,
 	geneticTempMultiplier float64,
-) ([25]byte, float64) {
-	ciphertext := poolData.global.ciphertext
-	excludedLetter := poolData.global.excludedLetter
-	separatorLetter := poolData.global.separatorLetter
+) (keySet, float64) {
+	grids := poolData.global.opts.Cipher.Grids()
 
-	currentKey := startingKey
-	currentScore := score.ScoreTextFast(cipher.PlayfairDecrypt(ciphertext, currentKey, excludedLetter), separatorLetter)
+	currentKey := current.keys
+	currentScore := current.score
+	defer func() {
+		current.keys, current.score = currentKey, currentScore
+	}()
 
 	bestKey := currentKey
 	bestScore := currentScore
 
+	// Keys are held to the crib placement the current key fits, checking it
+	// costs far less than scoring a key that breaks it
+	crib := poolData.global.crib
+	placement := -1
+	if crib != nil {
+		placement = crib.fit(currentKey.grids[0])
+	}
+
 	iterSinceBest := 0
 	iter := 0
 	for curTemp := initialTemp; curTemp >= floorTemp; curTemp *= (1 - coolingRate) {
-		// Check for other found solution or is solving
+		// Check for other found solution
 		select {
 		case <-ctx.Done():
 			// exit early
 			return bestKey, bestScore
 		default:
-			// Wait if some thread is checking for solution
-			poolData.global.chanLock.Lock()
-			poolData.global.chanLock.Unlock()
 		}
 
 		for index := 0; index < triesPerEpoch; index++ {
 			// We have stagnated, check if we are at solution
-			if -3000 < bestScore && iterSinceBest > triesBeforeStagnation {
+			if poolData.global.opts.ScoreGate < bestScore && iterSinceBest > triesBeforeStagnation {
 				return bestKey, bestScore
 			}
 
-			candidateKey := cipher.PermuteKey(currentKey, excludedLetter)
-			candidatePlaintext := cipher.PlayfairDecrypt(ciphertext, candidateKey, excludedLetter)
-			candidateScore := score.ScoreTextFast(candidatePlaintext, separatorLetter)
+			candidateKey := currentKey.permute(rng, grids, poolData.global.pins)
+			if placement < 0 || crib.placements[placement].holds(candidateKey.grids[0]) {
+				candidateScore := poolData.global.scoreKey(candidateKey)
 
-			// Calculate acceptance rate as function of current temperature
-			delta := candidateScore - currentScore
-			deltaRatio := delta / curTemp
-			acceptanceRate := math.Exp(deltaRatio)
+				// Calculate acceptance rate as function of current temperature
+				delta := candidateScore - currentScore
+				deltaRatio := delta / curTemp
+				acceptanceRate := math.Exp(deltaRatio)
 
-			if rand.Float64() < acceptanceRate {
-				currentScore = candidateScore
-				currentKey = candidateKey
+				if rng.Float64() < acceptanceRate {
+					currentScore = candidateScore
+					currentKey = candidateKey
+				}
 			}
 
 			// New global best, report it
@@ -88,7 +94,7 @@ func simulatedAnnealingCrack(
 			poolData.bestScore = bestScore
 			poolData.bestKey = bestKey
 
-			bestPlaintext := cipher.PlayfairDecrypt(ciphertext, bestKey, excludedLetter)
+			bestPlaintext := poolData.global.decrypt(bestKey)
 
 			if logVerbose {
 				timestamp := time.Now().Format("15:04:05")
@@ -101,12 +107,28 @@ func simulatedAnnealingCrack(
 			}
 		}
 		poolData.bestLock.Unlock()
+		poolData.global.leaders.offer(keyData{score: bestScore, keys: bestKey})
+
+		// Share keys with the pool, every worker reports each epoch
+		poolKeys, ok := exchangeKeys(ctx, poolData, pid, keyData{score: currentScore, keys: currentKey})
+		if !ok {
+			return bestKey, bestScore
+		}
+
+		// The pool moved on to another period, anneal its keys from the start
+		if poolKeys[pid].keys.period != currentKey.period {
+			currentKey, currentScore = poolKeys[pid].keys, poolKeys[pid].score
+			return bestKey, bestScore
+		}
 
 		// Step annealing genetic algo with prob e^-temp/max_temp
 		// acceptanceRate := math.Exp(-curTemp / initialTemp)
-		if rand.Float64() < 0.5 {
-			newKeyData := geneticSimulatedAnnealingStep(poolData, pid, geneticTempMultiplier*curTemp, currentKey, currentScore)
-			currentKey, currentScore = newKeyData.key, newKeyData.score
+		if rng.Float64() < 0.5 {
+			newKeyData := geneticSimulatedAnnealingStep(rng, poolKeys, geneticTempMultiplier*curTemp)
+			currentKey, currentScore = newKeyData.keys, newKeyData.score
+			if crib != nil {
+				placement = crib.fit(currentKey.grids[0])
+			}
 		}
 	}
 
@@ -114,22 +136,14 @@ func simulatedAnnealingCrack(
 }
 
 func geneticSimulatedAnnealingStep(
-	poolData *poolData,
-	pid int,
+	rng *rand.Rand,
+	poolKeys []keyData,
 	temp float64,
-	bestKey [25]byte,
-	bestScore float64,
 ) keyData {
-	poolData.currentLock.Lock()
-	defer poolData.currentLock.Unlock()
-
-	poolData.currentKeys[pid].key = bestKey
-	poolData.currentKeys[pid].score = bestScore
-
 	// Generate energies
-	pdf := make([]float64, len(poolData.currentKeys))
+	pdf := make([]float64, len(poolKeys))
 	sum := 0.0
-	for i, keyData := range poolData.currentKeys {
+	for i, keyData := range poolKeys {
 		pdf[i] = math.Exp(keyData.score / temp) // e^E_i/T
 		sum += pdf[i]
 	}
@@ -146,13 +160,13 @@ func geneticSimulatedAnnealingStep(
 	}
 
 	// Pick next key
-	point := rand.Float64()
+	point := rng.Float64()
 	for i, energy := range pdf {
 		if point < energy {
-			return poolData.currentKeys[i]
+			return poolKeys[i]
 		}
 	}
 
 	// If float error, fallback on last
-	return poolData.currentKeys[len(poolData.currentKeys)-1]
+	return poolKeys[len(poolKeys)-1]
 }
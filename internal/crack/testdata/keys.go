@@ -0,0 +1,95 @@
+package testdata
+
+// BenchKeys holds the key of each of BenchCiphertexts, in canonical form
+var BenchKeys = []string{
+	"ASQKDFYEIXWRHZMNLUPVOGBTC",
+	"ABNFRIPZWKYTHVUOSEGQLMCXD",
+	"ANDYOQFRCXBZWHTIPUGELVSKM",
+	"AVBCPFZTODIELWUXQRKMNHYGS",
+	"ALVHWUBSGTXQDCZFPENKIRMOY",
+	"AUHFYRNTDSPKZBGEOLCIVXWMQ",
+	"AOIMLTGKVWBHERCDYSPZNUQFX",
+	"AGNPDZUMYTWKQSXVREIHLBFOC",
+	"AGBLUSPXZROFTCVDEMYHQWNIK",
+	"ALZYOPCEMIKSXFQDBRVWGTUNH",
+	"AOWVDYLKXRIHMSUGNTQCPEFBZ",
+	"ABCOQNPSGYEWHLKFURXIVZTMD",
+	"AENTGXLQUVZDHRWFOSKPMCBYI",
+	"AKNHMVYSIZRFGQOWPEBULTCXD",
+	"AOYUNIDVEBCFXZLGRSTPKMQHW",
+	"AUPEDLBQFYXKISMOZWTGHRNVC",
+	"AWPNGKFYOZHXDMURSBQLVECTI",
+	"ASCFGDEUQYVOPXNBZMKWHTRIL",
+	"AZQIEBTORHYWSVDLNKFPXMCGU",
+	"ANHEUOWYLVTZBSRGMDKQIFXPC",
+	"AKDYURIOFLBQPVTEZHXCMNGSW",
+	"AMYFUTOIRHZQLGSWDENXKCBVP",
+	"AUSPRTXYVQCFLOEIHMGBZWNDK",
+	"ADKZYMGNCWRBPLEOVIHXFSQTU",
+	"AUHWMQBZIPDXGELCYNKRVFSOT",
+	"AGYEVOLBTHWSMURFZQIPDXKNC",
+	"AQIBPTSEYWFVMZRHCXODGUKLN",
+	"AEYQFGBWVMRUIHKTOCXZSPLDN",
+	"AWLGSIPZCERQNDYBOUXHTMKVF",
+	"AFOWRUYVBSHKZNTQCMLPIEGXD",
+	"AOIKWFUQZMSYTDNBVCHLGRXPE",
+	"AUFDNMBKRTIGPCEHZSXWOQVLY",
+	"APWEGBMNVRSFKQXHLDOYZUCTI",
+	"APSOBIXURLNWEQYFVDGCKHZTM",
+	"AKCIVFPTHDYSLZNXUBGERQMWO",
+	"AIPOXVTLQMHKGZCURDBEWNSYF",
+	"AMYWNKZUQFSHVDBLIRCOTPXGE",
+	"AEBHLMUGCSVYKXTDPNIQZORFW",
+	"AIZVKSXUDNTWHCRELGPYFQBMO",
+	"AXMRFOHYLDKGWQIBVTZUSPECN",
+	"AUMCRDLWVHFISXONZKBGQPETY",
+	"ANPEZHGVRSMDBUCQLKFYITOXW",
+	"AZITQDMVKHESNXYBWFUORCPLG",
+	"AIQNFGVMZHBECXYKWRUTOSDLP",
+	"AXFUDBWCTISEGZLYHOPQVMRNK",
+	"AFTWSZRCPYDBIUHXMEVKONLGQ",
+	"AKEXOGRUHSTQNDPIZLYMCFBVW",
+	"AZNTEXDWQCUOHMSFGVIBLKRYP",
+	"AKUVMWXYPHOINFTLESBDQRGCZ",
+	"AKMWSBFEGVDIRLQOPYZHXCNUT",
+	"AGYDQCRSIUTPXMVHLFNKWBOZE",
+	"AZEDQKTPGYCNUHBSFMRWLIOVX",
+	"ASUYNZFLWTXVHPMKDEROBGICQ",
+	"AOTUKEYFQXNGCHVLZDBPSRIMW",
+	"ALDVGOFKTHUWXZIYPMQBNCESR",
+	"AGZRNDSCBUPEQWYLOFHVIMTXK",
+	"AGLUQSIXDCWYFTZVOPKHMREBN",
+	"AHGRFCXVQTOZYUNDIWSMLPEBK",
+	"ANZDTHKSQRFCEVOLGBIMUYXWP",
+	"ANBZRCVGPEOSHDYMKQWFTXUIL",
+	"AGCRUESDLFIWPKXZYNVMOHTQB",
+	"AIFZRDQCVBKYETWNPSOLHUXGM",
+	"ASWKMEOCPBGVNFDTURIYQXLHZ",
+	"AZNKGRDUHVQSMCWXLFTOIPBYE",
+	"ANYCOZTHLEVIXKUMWSPFRGBQD",
+	"AHTDZLFGVMEPWSCBKXQNYIORU",
+	"ASDQTHPNKRWYCMILXGOFEZBVU",
+	"AWXLUCOIKTFDPQVMZRYHNBESG",
+	"AIVKBUZHRTOSQWXEFNMGCDLYP",
+	"AXHRIETKDBSNZUVOMFWPLQCGY",
+	"AKTSUXCIHOPFBYNDEGQVLWMRZ",
+	"AZMLVUYSTIERKCOXGHNWDQBFP",
+	"AUSDRLQHBOXPMFZEVIWTKCNYG",
+	"APEZVMXDIUFWNRHCLBGOKTSQY",
+	"AIKDUNEHMWYFSOBLRPZXQVGCT",
+	"AYZVSGQLKBENMIHUTODCFXPRW",
+	"AHQTWKOSENGYLZIXRFBUMPCDV",
+	"AYQIFSTGNVLKZUBMWEXPHDOCR",
+	"AMTFHOSEDUQKBYNGPRICVLWXZ",
+	"ATDHGSWZBKVFPQUOCXIREMLYN",
+	"ACBQWFZRHMYUDPTELVGSNIKOX",
+	"AQOVKUFZYXBWDIHLSTGRMPNCE",
+	"AOKTZXBHQYPIMRDEWCFGVNSUL",
+	"AXKOFLTMSGNUPZYQVDHRWECIB",
+	"AXEQFHBWPMKYOSVRTGLIDCNUZ",
+	"ASLYOTBUMEQPXKIDCFNVGZHWR",
+	"ATLESHNFYUZWGMKPXQRDCOBVI",
+	"AFYILPCUVNXTZGHOKWMQRESBD",
+	"APYSUGVWCXDMOEFLTRQHBKZNI",
+	"ARQNYWHBOFSDEUXKGPZICLTMV",
+}
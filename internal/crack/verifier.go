@@ -0,0 +1,130 @@
+package crack
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type checkResult struct {
+	keys     keySet
+	solution CrackResult
+	ok       bool
+}
+
+// runVerifier queues the candidates submitted by workers and checks them one
+// at a time with check on a separate goroutine, highest fast score first.
+// Keys already queued or checked are dropped, so workers never wait on the
+// slow check.
+func runVerifier(
+	ctx context.Context,
+	waitGroup *sync.WaitGroup,
+	globalData *globalData,
+	check func(globalData *globalData, keys keySet) (CrackResult, bool),
+	solutions chan<- CrackResult,
+) {
+	defer waitGroup.Done()
+
+	// The checker finishes its current key after checks closes and is waited
+	// for before returning, results is buffered so that it never blocks on a
+	// verifier that has stopped reading
+	checks := make(chan keySet)
+	results := make(chan checkResult, 1)
+	checkerDone := make(chan struct{})
+	defer func() {
+		close(checks)
+		<-checkerDone
+	}()
+	go func() {
+		defer close(checkerDone)
+		for keys := range checks {
+			solution, ok := check(globalData, keys)
+			results <- checkResult{keys: keys, solution: solution, ok: ok}
+		}
+	}()
+
+	seen := make(map[keySet]bool)
+	var queue []keyData
+	checking := false
+
+	for {
+		// Only offer the next key once the checker is free
+		var next keyData
+		var checkChan chan keySet
+		if !checking && len(queue) > 0 {
+			next = queue[len(queue)-1]
+			checkChan = checks
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case candidate := <-globalData.candidates:
+			// Rotations of a rejected key are rejected too
+			canonical := candidate.keys.canonical(globalData.opts.Cipher)
+			if seen[canonical] {
+				continue
+			}
+			seen[canonical] = true
+			queue = insertByScore(queue, candidate)
+		case checkChan <- next.keys:
+			queue = queue[:len(queue)-1]
+			checking = true
+		case result := <-results:
+			checking = false
+			if result.ok {
+				solutions <- result.solution
+				return
+			}
+
+			if globalData.opts.LogVerbose {
+				fmt.Printf("Rejected %s, English Word Score %2.2f, %d queued\n", result.keys, 100.0*result.solution.PercentEnglish, len(queue))
+			}
+		}
+	}
+}
+
+// insertByScore keeps queue sorted by ascending score.
+func insertByScore(queue []keyData, candidate keyData) []keyData {
+	i := len(queue)
+	for i > 0 && queue[i-1].score > candidate.score {
+		i--
+	}
+	queue = append(queue, keyData{})
+	copy(queue[i+1:], queue[i:])
+	queue[i] = candidate
+	return queue
+}
+
+// submitCandidate hands key to the verifier, returning false if ctx is done
+// first.
+func submitCandidate(ctx context.Context, globalData *globalData, candidate keyData) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case globalData.candidates <- candidate:
+		return true
+	}
+}
+
+// checkForSolution runs the slow English check on the decryption under keys.
+func checkForSolution(globalData *globalData, keys keySet) (CrackResult, bool) {
+	// Check for solution
+	solution := CrackResult{}
+	plaintext := globalData.decrypt(keys)
+	solution.PercentEnglish, solution.SegmentedText = globalData.scoreEnglish(plaintext)
+
+	// We did not find solution
+	if solution.PercentEnglish < THRESHHOLD_ENGLISH {
+		return solution, false
+	}
+
+	// Add result data
+	solution.Key = keys.String()
+	solution.Period = keys.period
+	solution.Plaintext = string(plaintext)
+	solution.Score = globalData.scorer.Score(plaintext, globalData.separatorLetter)
+	solution.Confirmed = true
+
+	return solution, true
+}
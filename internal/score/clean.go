@@ -0,0 +1,184 @@
+package score
+
+import (
+	"math/bits"
+	"playfaircrack/internal/cipher"
+	"slices"
+	"strings"
+)
+
+const (
+	// Letters of context scored on either side of a letter being decided on
+	CLEAN_CONTEXT = 3
+	// Most replaced letters in a word tried in every combination against
+	// the dictionary
+	CLEAN_MAX_REPLACED = 4
+)
+
+// CleanPlaintext undoes what preparing the plaintext did to decrypted text,
+// as far as the dictionary and the English ngrams can tell: it takes out the
+// letters convention split doubled letters with and the padding at the end,
+// then writes back excluded wherever replacement stood for it, leaving
+// replacement alone when it is 0. A Seriated Playfair period above 1, or
+// convention splitting every double, can put separators on either letter of
+// a digraph, otherwise only the second letter can be one.
+func CleanPlaintext(text []byte, sep byte, convention cipher.Convention, period int, excluded byte, replacement byte) string {
+	if len(text) == 0 {
+		return ""
+	}
+
+	scorer := GetNgramScorerInstance()
+	aligned := period <= 1 && convention != cipher.SPLIT_ALL_CONVENTION
+	cleaned := removeSeparators(scorer, text, sep, convention.Null(sep), aligned)
+	cleaned = removePadding(cleaned, sep, convention.Null(sep))
+	if replacement != 0 {
+		cleaned = restoreExcluded(scorer, cleaned, excluded, replacement)
+	}
+	return string(cleaned)
+}
+
+// removeSeparators takes out every sep between two equal letters, and every
+// null between two seps, that the text reads better without. Aligned
+// separators only ever stand at odd positions of text.
+func removeSeparators(scorer *NgramScorer, text []byte, sep byte, null byte, aligned bool) []byte {
+	cleaned := make([]byte, 0, len(text))
+	cleaned = append(cleaned, text[0])
+	for i := 1; i < len(text); i++ {
+		l := text[i]
+		separator := i+1 < len(text) && text[i-1] == text[i+1] && (l == sep || (null != 0 && l == null && text[i-1] == sep))
+		if separator && (!aligned || i%2 == 1) {
+			// Score both readings over the same stretch of the rest of text
+			before := cleaned[max(0, len(cleaned)-CLEAN_CONTEXT):]
+			after := text[i+1 : min(len(text), i+1+CLEAN_CONTEXT)]
+			kept := string(before) + string(l) + string(after)
+			removed := string(before) + string(after)
+			if scorer.local(removed) > scorer.local(kept) {
+				continue
+			}
+		}
+		cleaned = append(cleaned, l)
+	}
+	return cleaned
+}
+
+// removePadding takes a last sep, or a last null after sep, off text unless
+// it ends a dictionary word that is not one without it.
+func removePadding(text []byte, sep byte, null byte) []byte {
+	n := len(text)
+	last := text[n-1]
+	if last != sep && (null == 0 || last != null || n < 2 || text[n-2] != sep) {
+		return text
+	}
+
+	word := ""
+	for _, segmented := range GetSegmentorInstance().Segment(keepLetters(string(text))) {
+		if segmented != "" {
+			word = segmented
+		}
+	}
+	if len(word) > 1 && isWord(word) && !isWord(word[:len(word)-1]) {
+		return text
+	}
+	return text[:n-1]
+}
+
+// restoreExcluded writes excluded for every replacement standing for it: by
+// the dictionary for the words of text it finds with replacement in them,
+// by the ngrams around the rest.
+func restoreExcluded(scorer *NgramScorer, text []byte, excluded byte, replacement byte) []byte {
+	restored := []byte(strings.ToUpper(string(text)))
+	decided := make([]bool, len(restored))
+
+	// Words the segmentor gives back must line up with text to be of use
+	start := 0
+	for _, word := range GetSegmentorInstance().Segment(string(restored)) {
+		end := start + len(word)
+		if end > len(restored) || !strings.EqualFold(word, string(restored[start:end])) {
+			break
+		}
+		if variant, ok := dictionaryVariant(restored[start:end], excluded, replacement); ok {
+			copy(restored[start:end], variant)
+			for i := start; i < end; i++ {
+				decided[i] = true
+			}
+		}
+		start = end
+	}
+
+	for i, l := range restored {
+		if l != replacement || decided[i] {
+			continue
+		}
+		before := string(restored[max(0, i-CLEAN_CONTEXT):i])
+		after := string(restored[i+1 : min(len(restored), i+1+CLEAN_CONTEXT)])
+		if scorer.local(before+string(excluded)+after) > scorer.local(before+string(replacement)+after) {
+			restored[i] = excluded
+		}
+	}
+
+	return restored
+}
+
+// dictionaryVariant returns word, or word with some of its replacement
+// letters turned back into excluded, when that is a dictionary word,
+// preferring the fewest letters turned. Words without replacement, or with
+// too many of it to try, report false.
+func dictionaryVariant(word []byte, excluded byte, replacement byte) ([]byte, bool) {
+	for _, variant := range turnedVariants(word, excluded, replacement) {
+		if isWord(string(variant)) {
+			return variant, true
+		}
+	}
+	return nil, false
+}
+
+// turnedVariants returns word with every combination of its replacement
+// letters turned into excluded, fewest turned first, or nil when it holds
+// none of them or more than CLEAN_MAX_REPLACED.
+func turnedVariants(word []byte, excluded byte, replacement byte) [][]byte {
+	var replaced []int
+	for i, l := range word {
+		if l == replacement {
+			replaced = append(replaced, i)
+		}
+	}
+	if len(replaced) == 0 || len(replaced) > CLEAN_MAX_REPLACED {
+		return nil
+	}
+
+	turnings := make([]int, 1<<len(replaced))
+	for turned := range turnings {
+		turnings[turned] = turned
+	}
+	slices.SortStableFunc(turnings, func(a, b int) int {
+		return bits.OnesCount(uint(a)) - bits.OnesCount(uint(b))
+	})
+
+	variants := make([][]byte, 0, len(turnings))
+	for _, turned := range turnings {
+		variant := slices.Clone(word)
+		for bit, i := range replaced {
+			if turned&(1<<bit) != 0 {
+				variant[i] = excluded
+			}
+		}
+		variants = append(variants, variant)
+	}
+	return variants
+}
+
+// isWord reports whether word is in the dictionary.
+func isWord(word string) bool {
+	return len(GetDictionaryInstance().Find([]byte(strings.ToLower(word)), 0)) > 0
+}
+
+// local scores a short stretch of text by the mean of its trigrams and
+// quadgrams, to compare readings of the same stretch of different lengths.
+func (scorer *NgramScorer) local(text string) float64 {
+	letters := keepLetters(text)
+	count := max(0, len(letters)-2) + max(0, len(letters)-3)
+	if count == 0 {
+		return 0
+	}
+	return (scorer.trigrams.score(letters) + scorer.quadgrams.score(letters)) / float64(count)
+}
@@ -0,0 +1,33 @@
+package score
+
+import (
+	"playfaircrack/internal/cipher"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanPlaintext(t *testing.T) {
+	tests := []struct {
+		raw     string
+		cleaned string
+	}{
+		// Separators and padding come out, while X's reading better kept, or
+		// standing where no separator could, stay
+		{raw: "BALXLOONSEXECUTEDATNOXON", cleaned: "BALLOONSEXECUTEDATNOON"},
+		{raw: "IUSTATOXOKTHEIUICEFIXINGTHEOBIECTSX", cleaned: "JUSTATOOKTHEJUICEFIXINGTHEOBJECTS"},
+		{raw: "THEMAIORITYOFPEOPLEINIULY", cleaned: "THEMAJORITYOFPEOPLEINJULY"},
+		{raw: "ANNEXESWEREMIXEDWITHRELAXATIONANDTHEBOXOFFICE", cleaned: "ANNEXESWEREMIXEDWITHRELAXATIONANDTHEBOXOFFICE"},
+		{raw: "WHEREISTHEBOXQ", cleaned: "WHEREISTHEBOX"},
+	}
+
+	for _, tt := range tests {
+		cleaned := CleanPlaintext([]byte(tt.raw), 'X', cipher.CLASSIC_CONVENTION, 0, 'J', 'I')
+		assert.Equal(t, tt.cleaned, cleaned, tt.raw)
+	}
+
+	// Nulls between doubled separators come out under the conventions using one
+	assert.Equal(t, "TAXXI", CleanPlaintext([]byte("TAXQXI"), 'X', cipher.QX_CONVENTION, 0, 'J', 'I'))
+	// Separators fall on even letters too when every double is split
+	assert.Equal(t, "BOOKKEEPER", CleanPlaintext([]byte("BOXOKXKEXEPERX"), 'X', cipher.SPLIT_ALL_CONVENTION, 0, 'J', 0))
+}
@@ -6,8 +6,11 @@ import (
 	"log"
 	"math"
 	"playfaircrack/assets"
+	"playfaircrack/internal/cipher"
+	"slices"
 	"strings"
 	"sync"
+	"unicode"
 
 	"github.com/agnivade/levenshtein"
 	"github.com/theosiemensrhodes/go-bktree"
@@ -50,7 +53,7 @@ type EnglishScorer struct {
 
 func (scorer *EnglishScorer) score(text []byte, power float64) float64 {
 	// Remove playfair separator
-	filteredText := RemovePlayfairSep(text, 'X')
+	filteredText := RemovePlayfairSep(text, 'X', cipher.CLASSIC_CONVENTION)
 
 	// Segment into words
 	words := scorer.segmentor.Segment(filteredText)
@@ -103,3 +106,31 @@ func GetDictionaryInstance() *bktree.BKTree {
 	})
 	return bktreeInstance
 }
+
+// inFoldedDictionary reports whether word, in lower case, is within distance
+// of a dictionary word once folded the way GetFoldedNgramScorer folds text:
+// excluded replaced by replacement, or dropped when replacement is 0. Rather
+// than holding a folded copy of the dictionary, the letters folding could
+// have taken away are tried back in, a replacement turned back into excluded
+// or one dropped excluded put back anywhere.
+func inFoldedDictionary(word []byte, distance int, excluded byte, replacement byte) bool {
+	excluded = byte(unicode.ToLower(rune(excluded)))
+	variants := [][]byte{word}
+	if replacement != 0 {
+		if turned := turnedVariants(word, excluded, byte(unicode.ToLower(rune(replacement)))); turned != nil {
+			variants = turned
+		}
+	} else {
+		for i := 0; i <= len(word); i++ {
+			variants = append(variants, slices.Concat(word[:i], []byte{excluded}, word[i:]))
+		}
+	}
+
+	dictionary := GetDictionaryInstance()
+	for _, variant := range variants {
+		if len(dictionary.Find(variant, distance)) > 0 {
+			return true
+		}
+	}
+	return false
+}
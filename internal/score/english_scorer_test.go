@@ -0,0 +1,15 @@
+package score
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInFoldedDictionary(t *testing.T) {
+	assert.True(t, inFoldedDictionary([]byte("iuly"), 0, 'J', 'I'))
+	assert.True(t, inFoldedDictionary([]byte("thicc"), 0, 'K', 'C'))
+	assert.True(t, inFoldedDictionary([]byte("ueen"), 0, 'Q', 0))
+	assert.False(t, inFoldedDictionary([]byte("iuly"), 0, 'K', 'C'))
+	assert.False(t, inFoldedDictionary([]byte("ueen"), 0, 'Q', 'K'))
+}
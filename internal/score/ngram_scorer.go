@@ -5,6 +5,7 @@ import (
 	"log"
 	"math"
 	"playfaircrack/assets"
+	"playfaircrack/internal/cipher"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,13 +18,28 @@ type NgramScore struct {
 	floor  float64
 }
 
+// ngramCounts are the counts of the ngrams of a table, all of length L, with
+// N the total count of the table as read.
+type ngramCounts struct {
+	counts map[string]float64
+	L      int
+	N      int
+}
+
 func NewNgramScore(data string) (*NgramScore, error) {
+	table, err := readNgramCounts(data)
+	if err != nil {
+		return nil, err
+	}
+	return table.ngramScore(), nil
+}
+
+// readNgramCounts reads a table of ngrams, one ngram and its count per line.
+func readNgramCounts(data string) (*ngramCounts, error) {
 	reader := strings.NewReader(data)
 	scanner := bufio.NewScanner(reader)
 
-	var ngramMap = make(map[string]float64)
-	var totalCount int
-	var ngramLength int
+	table := &ngramCounts{counts: make(map[string]float64)}
 	var firstLine bool = true
 
 	for scanner.Scan() {
@@ -38,23 +54,86 @@ func NewNgramScore(data string) (*NgramScore, error) {
 			return nil, err
 		}
 		if firstLine {
-			ngramLength = len(key)
+			table.L = len(key)
 			firstLine = false
 		}
-		ngramMap[key] = float64(count)
-		totalCount += count
+		table.N += count
+		table.counts[key] += float64(count)
 	}
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
 
-	if ngramLength < 2 || ngramLength > 4 {
+	if table.L < 2 || table.L > 4 {
 		panic("ngram length is not 2, 3, or 4")
 	}
+	return table, nil
+}
+
+// replace returns the counts with excluded replaced by replacement in every
+// ngram, ngrams that become the same adding up.
+func (table *ngramCounts) replace(excluded byte, replacement byte) *ngramCounts {
+	folded := &ngramCounts{counts: make(map[string]float64, len(table.counts)), L: table.L, N: table.N}
+	for ngram, count := range table.counts {
+		folded.counts[strings.ReplaceAll(ngram, string(excluded), string(replacement))] += count
+	}
+	return folded
+}
 
+// omit returns the counts of the ngrams of text with omitted taken out: the
+// ngrams without it, and the ngrams of longer, one letter longer, that shrink
+// to one by losing omitted from between their ends. An omitted letter at the
+// end of a longer ngram leaves an ngram that is counted as it is already.
+func (table *ngramCounts) omit(omitted byte, longer map[string]float64) *ngramCounts {
+	folded := &ngramCounts{counts: make(map[string]float64, len(table.counts)), L: table.L, N: table.N}
+	for ngram, count := range table.counts {
+		if strings.IndexByte(ngram, omitted) < 0 {
+			folded.counts[ngram] += count
+		}
+	}
+	for ngram, count := range longer {
+		inner := ngram[1 : len(ngram)-1]
+		if strings.Count(inner, string(omitted)) == 1 && ngram[0] != omitted && ngram[len(ngram)-1] != omitted {
+			folded.counts[strings.ReplaceAll(ngram, string(omitted), "")] += count
+		}
+	}
+	return folded
+}
+
+// extend estimates the counts of the ngrams one letter longer than those of
+// table that hold omitted past their first letter, for the longest table
+// which has no longer one to draw on. Each is the chain of two ngrams of
+// table overlapping on an ngram of shorter, counted as often as the second
+// follows the overlap. Counts are rounded so that adding them up never
+// depends on the order they come in.
+func (table *ngramCounts) extend(shorter *ngramCounts, omitted byte) map[string]float64 {
+	following := make(map[string][]string)
+	for ngram := range table.counts {
+		following[ngram[:table.L-1]] = append(following[ngram[:table.L-1]], ngram)
+	}
+
+	longer := make(map[string]float64)
+	for ngram, count := range table.counts {
+		overlap := ngram[1:]
+		if strings.IndexByte(overlap, omitted) < 0 || shorter.counts[overlap] == 0 {
+			continue
+		}
+		scale := count / shorter.counts[overlap] * float64(shorter.N) / float64(table.N)
+		for _, next := range following[overlap] {
+			if estimate := math.Round(scale * table.counts[next]); estimate > 0 {
+				longer[ngram+next[table.L-1:]] = estimate
+			}
+		}
+	}
+	return longer
+}
+
+// ngramScore turns the counts into log probabilities, every ngram never
+// counted scoring the floor.
+func (table *ngramCounts) ngramScore() *NgramScore {
 	// Precompute size (26^L)
 	var size int
-	switch ngramLength {
+	switch table.L {
 	case 2:
 		size = 26 * 26
 	case 3:
@@ -64,8 +143,8 @@ func NewNgramScore(data string) (*NgramScore, error) {
 	}
 
 	scorer := &NgramScore{
-		L: ngramLength,
-		N: totalCount,
+		L: table.L,
+		N: table.N,
 	}
 	scorer.floor = math.Log10(0.01 / float64(scorer.N))
 
@@ -77,13 +156,13 @@ func NewNgramScore(data string) (*NgramScore, error) {
 
 	// Fill known ngrams
 	totalFloat := float64(scorer.N)
-	for k, v := range ngramMap {
+	for k, v := range table.counts {
 		logProb := math.Log10(v / totalFloat)
 		idx := ngramToIndex(k, scorer.L)
 		scorer.ngrams[idx] = logProb
 	}
 
-	return scorer, nil
+	return scorer
 }
 
 func ngramToIndex(ngram string, L int) int {
@@ -124,44 +203,86 @@ type NgramScorer struct {
 var (
 	ngramScoreInstance *NgramScorer
 	ngramScoreOnce     sync.Once
+
+	foldedScorers     = make(map[[2]byte]*NgramScorer)
+	foldedScorersLock sync.Mutex
 )
 
 func GetNgramScorerInstance() *NgramScorer {
 	ngramScoreOnce.Do(func() {
-		ngramScoreInstance = &NgramScorer{}
+		ngramScoreInstance = newNgramScorer(0, 0)
+	})
+	return ngramScoreInstance
+}
 
-		// load bigrams, trigrams, and quadgrams in parallel
-		var wg sync.WaitGroup
-		wg.Add(3)
+// GetFoldedNgramScorer returns a scorer for text in which excluded has been
+// replaced by replacement, or dropped when replacement is 0, so that the
+// ngrams of words holding excluded still score as English. Dropping a letter
+// brings the letters on either side of it together, their ngrams are counted
+// from the ngrams that held it.
+func GetFoldedNgramScorer(excluded byte, replacement byte) *NgramScorer {
+	foldedScorersLock.Lock()
+	defer foldedScorersLock.Unlock()
 
-		go func() {
-			defer wg.Done()
-			var err error
-			ngramScoreInstance.bigrams, err = NewNgramScore(assets.Bigrams)
-			if err != nil {
-				log.Fatal(err)
-			}
-		}()
+	rule := [2]byte{excluded, replacement}
+	if scorer, ok := foldedScorers[rule]; ok {
+		return scorer
+	}
 
-		go func() {
-			defer wg.Done()
-			var err error
-			ngramScoreInstance.trigrams, err = NewNgramScore(assets.Trigrams)
-			if err != nil {
-				log.Fatal(err)
-			}
-		}()
+	scorer := newNgramScorer(excluded, replacement)
+	foldedScorers[rule] = scorer
+	return scorer
+}
 
+// Score sums the bigram, trigram and quadgram scores of text with the
+// Playfair separators, and anything but letters, taken out. Every symbol
+// taken out, such as the digits of larger grids, scores the floor of each
+// table, so that decrypting to symbols rather than letters gains nothing.
+func (scorer *NgramScorer) Score(text []byte, sep byte) float64 {
+	sepFiltered := RemovePlayfairSep(text, sep, cipher.CLASSIC_CONVENTION)
+	letters := keepLetters(sepFiltered)
+	symbols := float64(len(sepFiltered) - len(letters))
+	return scorer.bigrams.score(letters) + scorer.trigrams.score(letters) + scorer.quadgrams.score(letters) +
+		symbols*(scorer.bigrams.floor+scorer.trigrams.floor+scorer.quadgrams.floor)
+}
+
+// newNgramScorer loads the ngram tables folded as GetFoldedNgramScorer
+// describes, as they are when excluded is 0.
+func newNgramScorer(excluded byte, replacement byte) *NgramScorer {
+	// load bigrams, trigrams, and quadgrams in parallel
+	tables := make([]*ngramCounts, 3)
+	var wg sync.WaitGroup
+	wg.Add(len(tables))
+
+	for i, data := range []string{assets.Bigrams, assets.Trigrams, assets.Quadgrams} {
 		go func() {
 			defer wg.Done()
 			var err error
-			ngramScoreInstance.quadgrams, err = NewNgramScore(assets.Quadgrams)
+			tables[i], err = readNgramCounts(data)
 			if err != nil {
 				log.Fatal(err)
 			}
 		}()
+	}
+	wg.Wait()
 
-		wg.Wait()
-	})
-	return ngramScoreInstance
+	switch {
+	case excluded == 0:
+	case replacement != 0:
+		for i, table := range tables {
+			tables[i] = table.replace(excluded, replacement)
+		}
+	default:
+		// Every table but the longest draws on the next one up
+		longer := []map[string]float64{tables[1].counts, tables[2].counts, tables[2].extend(tables[1], excluded)}
+		for i, table := range tables {
+			tables[i] = table.omit(excluded, longer[i])
+		}
+	}
+
+	return &NgramScorer{
+		bigrams:   tables[0].ngramScore(),
+		trigrams:  tables[1].ngramScore(),
+		quadgrams: tables[2].ngramScore(),
+	}
 }
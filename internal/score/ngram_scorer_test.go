@@ -0,0 +1,35 @@
+package score
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFoldedScorer(t *testing.T) {
+	plaintext := "THEQUEENQUICKLYQUESTIONEDTHEQUIETQUARTERMASTERABOUTTHEQUALITYOFTHEQUILTS"
+	plain := GetNgramScorerInstance()
+
+	// Merged words like THICC score as THICK does
+	merged := []byte(strings.ReplaceAll(plaintext, "K", "C"))
+	mergedScorer := GetFoldedNgramScorer('K', 'C')
+	assert.Greater(t, mergedScorer.Score(merged, 'X'), plain.Score(merged, 'X'))
+	assert.Same(t, mergedScorer, GetFoldedNgramScorer('K', 'C'))
+
+	// Omitted letters bring their neighbours together, THEUEEN scoring as
+	// THEQUEEN does
+	omitted := []byte(strings.ReplaceAll(plaintext, "Q", ""))
+	omittedScorer := GetFoldedNgramScorer('Q', 0)
+	assert.Greater(t, omittedScorer.Score(omitted, 'X'), plain.Score(omitted, 'X'))
+	assert.Greater(t, omittedScorer.trigrams.score("THEUEEN"), plain.trigrams.score("THEUEEN"))
+	assert.Greater(t, omittedScorer.quadgrams.score("THEUEEN"), plain.quadgrams.score("THEUEEN"))
+}
+
+func TestScoreSymbols(t *testing.T) {
+	// Dropping the digits of a larger grid gains nothing over letters
+	scorer := GetNgramScorerInstance()
+	floors := scorer.bigrams.floor + scorer.trigrams.floor + scorer.quadgrams.floor
+	assert.InDelta(t, scorer.Score([]byte("ATTACKAT"), 'X')+4*floors, scorer.Score([]byte("ATTACKAT0600"), 'X'), 1e-9)
+	assert.Less(t, scorer.Score([]byte("ATTACKAT0600"), 'X'), scorer.Score([]byte("ATTACKATDAWN"), 'X'))
+}
@@ -2,24 +2,37 @@ package score
 
 import (
 	"math"
+	"playfaircrack/internal/cipher"
 	"strings"
 )
 
 func ScoreTextFast(text []byte, sep byte) float64 {
-	scorer := GetNgramScorerInstance()
+	return GetNgramScorerInstance().Score(text, sep)
+}
 
-	// Filter playfair separator
-	sepFiltered := RemovePlayfairSep(text, sep)
+func ScoreTextSlow(text []byte, sep byte, power float64) (float64, []string) {
+	return scoreTextSlow(text, sep, power, func(word []byte, distance int) bool {
+		return len(GetDictionaryInstance().Find(word, distance)) > 0
+	})
+}
 
-	return scorer.bigrams.score(sepFiltered) + scorer.trigrams.score(sepFiltered) + scorer.quadgrams.score(sepFiltered)
+// ScoreFoldedTextSlow is ScoreTextSlow for text in which excluded has been
+// replaced by replacement, or dropped when replacement is 0, looking its
+// words up as inFoldedDictionary does. The segmentor itself only knows the
+// words as they are spelled.
+func ScoreFoldedTextSlow(text []byte, sep byte, power float64, excluded byte, replacement byte) (float64, []string) {
+	return scoreTextSlow(text, sep, power, func(word []byte, distance int) bool {
+		return inFoldedDictionary(word, distance, excluded, replacement)
+	})
 }
 
-func ScoreTextSlow(text []byte, sep byte, power float64) (float64, []string) {
+// scoreTextSlow scores the words of text found by inDictionary within a
+// distance of 0, 1 or 2 of a dictionary word.
+func scoreTextSlow(text []byte, sep byte, power float64, inDictionary func(word []byte, distance int) bool) (float64, []string) {
 	segmentor := GetSegmentorInstance()
-	dict := GetDictionaryInstance()
 
-	// Remove playfair separator
-	filteredText := RemovePlayfairSep(text, sep)
+	// Remove playfair separator and any digits or symbols of larger grids
+	filteredText := keepLetters(RemovePlayfairSep(text, sep, cipher.CLASSIC_CONVENTION))
 
 	// Segment into words
 	words := segmentor.Segment(filteredText)
@@ -30,11 +43,11 @@ func ScoreTextSlow(text []byte, sep byte, power float64) (float64, []string) {
 	for _, word := range words {
 		byte_word := []byte(strings.ToLower(word))
 
-		if len(dict.Find(byte_word, 0)) > 0 {
+		if inDictionary(byte_word, 0) {
 			english_char_count += math.Pow(float64(len(word)), power)
-		} else if len(dict.Find(byte_word, 1)) > 0 {
+		} else if inDictionary(byte_word, 1) {
 			english_char_count += math.Pow(float64(len(word)*2/3), power)
-		} else if len(dict.Find(byte_word, 2)) > 0 {
+		} else if inDictionary(byte_word, 2) {
 			english_char_count += math.Pow(float64(len(word)*1/3), power)
 		}
 
@@ -45,11 +58,16 @@ func ScoreTextSlow(text []byte, sep byte, power float64) (float64, []string) {
 	return (english_char_count / total_char_count), words
 }
 
-func RemovePlayfairSep(text []byte, sep byte) string {
+// RemovePlayfairSep takes out the letters convention splits doubled letters
+// with: sep between two equal letters, and the null of convention between two
+// seps.
+func RemovePlayfairSep(text []byte, sep byte, convention cipher.Convention) string {
+	null := convention.Null(sep)
+
 	// Filter playfair X pattern
 	filtered := []byte{text[0]}
 	for i := 1; i < len(text)-1; i++ {
-		if text[i] == sep && text[i-1] == text[i+1] {
+		if text[i-1] == text[i+1] && (text[i] == sep || (null != 0 && text[i] == null && text[i-1] == sep)) {
 			continue
 		}
 		filtered = append(filtered, text[i])
@@ -59,3 +77,19 @@ func RemovePlayfairSep(text []byte, sep byte) string {
 	filtered = append(filtered, text[len(text)-1])
 	return string(filtered)
 }
+
+// keepLetters drops everything but A-Z from text, which is returned as is
+// when it only holds letters already.
+func keepLetters(text string) string {
+	for i := 0; i < len(text); i++ {
+		if text[i] < 'A' || text[i] > 'Z' {
+			return strings.Map(func(r rune) rune {
+				if r < 'A' || r > 'Z' {
+					return -1
+				}
+				return r
+			}, text)
+		}
+	}
+	return text
+}